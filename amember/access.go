@@ -0,0 +1,144 @@
+package amember
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// GrantAccess gives the user access to the product from begin to expire, and returns the new access record.
+// If the user already has an access to the same product overlapping the given period, ErrOverlappingAccess is returned
+// and nothing is created, unless allowOverlap=true.
+func (am *Amember) GrantAccess(ctx context.Context, userID int, productID int, begin time.Time, expire time.Time, comment string, allowOverlap bool) (Access, error) {
+
+	if dateOnly(expire).Before(dateOnly(begin)) {
+		return Access{}, fmt.Errorf("grant access: expire date %s is before begin date %s", expire.Format("2006-01-02"), begin.Format("2006-01-02"))
+	}
+
+	if !allowOverlap {
+
+		p := Params{Filter: map[string]string{"user_id": strconv.Itoa(userID), "product_id": strconv.Itoa(productID)}}
+
+		accesses, err := am.AccessesContext(ctx, p, false)
+		if err != nil {
+			return Access{}, fmt.Errorf("grant access: %w", err)
+		}
+
+		for _, a := range accesses[userID] {
+
+			if int(a.ProductID) != productID {
+				continue
+			}
+
+			//two periods overlap when each one begins before the other one expires
+			if !dateOnly(a.BeginDate.Time).After(dateOnly(expire)) && !dateOnly(begin).After(dateOnly(a.ExpireDate.Time)) {
+				return Access{}, fmt.Errorf("grant access: %w: access %d [%s - %s]", ErrOverlappingAccess, a.AccessID,
+					a.BeginDate.Format("2006-01-02"), a.ExpireDate.Format("2006-01-02"))
+			}
+		}
+	}
+
+	form := url.Values{}
+	form.Set("user_id", strconv.Itoa(userID))
+	form.Set("product_id", strconv.Itoa(productID))
+	form.Set("begin_date", begin.Format("2006-01-02"))
+	form.Set("expire_date", expire.Format("2006-01-02"))
+	if comment != "" {
+		form.Set("comment", comment)
+	}
+
+	return am.writeAccess(ctx, http.MethodPost, am.APIURL+"/api/access", form)
+}
+
+// ExtendAccess moves the expire date of the access having the given access_id to newExpire, and returns the updated access record.
+// newExpire cannot be before the begin date of the access.
+func (am *Amember) ExtendAccess(ctx context.Context, accessID int, newExpire time.Time) (Access, error) {
+
+	a, err := am.access(ctx, accessID)
+	if err != nil {
+		return Access{}, fmt.Errorf("extend access: %w", err)
+	}
+
+	if dateOnly(newExpire).Before(dateOnly(a.BeginDate.Time)) {
+		return Access{}, fmt.Errorf("extend access %d: expire date %s is before begin date %s", accessID, newExpire.Format("2006-01-02"), a.BeginDate.Format("2006-01-02"))
+	}
+
+	return am.setAccessExpire(ctx, accessID, newExpire)
+}
+
+// RevokeAccess cuts the access having the given access_id now, by making it expire yesterday, and returns the updated access record.
+// The record is kept, so that the access history of the user stays intact. An access beginning today or later cannot expire
+// before it begins: it is deleted instead, and returned as it was.
+func (am *Amember) RevokeAccess(ctx context.Context, accessID int) (Access, error) {
+
+	a, err := am.access(ctx, accessID)
+	if err != nil {
+		return Access{}, fmt.Errorf("revoke access: %w", err)
+	}
+
+	//aMember considers an access active until the end of its expire date
+	yesterday := dateOnly(time.Now()).AddDate(0, 0, -1)
+
+	if dateOnly(a.BeginDate.Time).After(yesterday) {
+
+		_, err := am.doRequest(ctx, http.MethodDelete, fmt.Sprintf("%s/api/access/%d", am.APIURL, accessID), nil)
+		if err != nil {
+			return Access{}, fmt.Errorf("revoke access %d: %w", accessID, err)
+		}
+
+		return a, nil
+	}
+
+	return am.setAccessExpire(ctx, accessID, yesterday)
+}
+
+// access returns the access record having the given access_id
+func (am *Amember) access(ctx context.Context, accessID int) (Access, error) {
+
+	response, err := am.doRequest(ctx, http.MethodGet, fmt.Sprintf("%s/api/access/%d", am.APIURL, accessID), nil)
+	if err != nil {
+		return Access{}, fmt.Errorf("access %d: %w", accessID, err)
+	}
+
+	m, err := firstRecord(response)
+	if err != nil {
+		return Access{}, fmt.Errorf("access %d: %w", accessID, err)
+	}
+
+	return decodeRecord[Access](strconv.Itoa(accessID), m)
+}
+
+// setAccessExpire sets the expire date of the access having the given access_id
+func (am *Amember) setAccessExpire(ctx context.Context, accessID int, expire time.Time) (Access, error) {
+
+	form := url.Values{}
+	form.Set("expire_date", expire.Format("2006-01-02"))
+
+	return am.writeAccess(ctx, http.MethodPut, fmt.Sprintf("%s/api/access/%d", am.APIURL, accessID), form)
+}
+
+// writeAccess sends form to an access endpoint and decodes the access record returned by aMember
+func (am *Amember) writeAccess(ctx context.Context, method string, url string, form url.Values) (Access, error) {
+
+	response, err := am.doRequest(ctx, method, url, form)
+	if err != nil {
+		return Access{}, fmt.Errorf("%s access: %w", method, err)
+	}
+
+	m, err := firstRecord(response)
+	if err != nil {
+		return Access{}, fmt.Errorf("%s access: %w", method, err)
+	}
+
+	return decodeRecord[Access]("0", m)
+}
+
+// dateOnly returns the calendar date of t, in the location of t, as midnight UTC. aMember dates decode as midnight UTC,
+// so dates are compared with the ones of aMember, and with each other, as calendar dates whatever the local time zone.
+func dateOnly(t time.Time) time.Time {
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
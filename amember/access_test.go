@@ -0,0 +1,160 @@
+package amember_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paperclicks/gomember/amember"
+	"github.com/paperclicks/gomember/amember/amembertest"
+)
+
+// zones are the local time zones the access tests run in: aMember dates must compare as calendar dates in all of them
+var zones = []*time.Location{time.UTC, time.FixedZone("UTC+2", 2*3600), time.FixedZone("UTC-5", -5*3600), time.FixedZone("UTC+14", 14*3600)}
+
+// inZone makes loc the local time zone until the end of the test
+func inZone(t *testing.T, loc *time.Location) {
+
+	local := time.Local
+	time.Local = loc
+
+	t.Cleanup(func() { time.Local = local })
+}
+
+// newAccessServer returns a client of a server holding the given accesses of user 1
+func newAccessServer(t *testing.T, accesses ...amember.Access) *amember.Amember {
+
+	srv := amembertest.NewServer(amembertest.WithFixtures(amembertest.Fixtures{
+		Users:    []amember.User{{UserID: 1, Login: "jdoe"}},
+		Accesses: accesses,
+	}))
+	t.Cleanup(srv.Close)
+
+	am, err := srv.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return am
+}
+
+func date(y int, m time.Month, d int) amember.CustomTime {
+
+	return amember.CustomTime{Time: time.Date(y, m, d, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestGrantAccessOverlap(t *testing.T) {
+
+	//the existing access runs from March 10 to March 20, the dates of the cases are in the local time zone
+	tests := []struct {
+		beginDay  int
+		beginHour int
+		expireDay int
+		overlap   bool
+	}{
+		{beginDay: 1, expireDay: 9},
+		{beginDay: 1, expireDay: 10, overlap: true},
+		{beginDay: 20, beginHour: 23, expireDay: 25, overlap: true},
+		{beginDay: 21, expireDay: 25},
+	}
+
+	for _, loc := range zones {
+		t.Run(loc.String(), func(t *testing.T) {
+
+			inZone(t, loc)
+
+			am := newAccessServer(t, amember.Access{AccessID: 1, UserID: 1, ProductID: 1, BeginDate: date(2024, 3, 10), ExpireDate: date(2024, 3, 20)})
+
+			for _, tt := range tests {
+
+				begin := time.Date(2024, 3, tt.beginDay, tt.beginHour, 0, 0, 0, loc)
+				expire := time.Date(2024, 3, tt.expireDay, 0, 0, 0, 0, loc)
+
+				_, err := am.GrantAccess(context.Background(), 1, 1, begin, expire, "", false)
+
+				if got := errors.Is(err, amember.ErrOverlappingAccess); got != tt.overlap || (!tt.overlap && err != nil) {
+					t.Errorf("GrantAccess(%s, %s) = %v, want overlap %v", begin, expire, err, tt.overlap)
+				}
+			}
+		})
+	}
+}
+
+func TestExtendAccess(t *testing.T) {
+
+	for _, loc := range zones {
+		t.Run(loc.String(), func(t *testing.T) {
+
+			inZone(t, loc)
+
+			am := newAccessServer(t, amember.Access{AccessID: 1, UserID: 1, ProductID: 1, BeginDate: date(2024, 3, 1), ExpireDate: date(2024, 4, 1)})
+
+			//expiring the day the access begins is allowed
+			a, err := am.ExtendAccess(context.Background(), 1, time.Date(2024, 3, 1, 0, 0, 0, 0, loc))
+			if err != nil {
+				t.Errorf("ExtendAccess to the begin date: %v", err)
+			} else if !a.ExpireDate.Equal(date(2024, 3, 1).Time) {
+				t.Errorf("ExtendAccess to the begin date: expire date %s, want 2024-03-01", a.ExpireDate)
+			}
+
+			_, err = am.ExtendAccess(context.Background(), 1, time.Date(2024, 2, 29, 23, 0, 0, 0, loc))
+			if err == nil {
+				t.Errorf("ExtendAccess to the day before the begin date: want an error")
+			}
+		})
+	}
+}
+
+func TestRevokeAccess(t *testing.T) {
+
+	for _, loc := range zones {
+		t.Run(loc.String(), func(t *testing.T) {
+
+			inZone(t, loc)
+
+			now := time.Now()
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+			yesterday := today.AddDate(0, 0, -1)
+			expire := amember.CustomTime{Time: today.AddDate(0, 1, 0)}
+
+			am := newAccessServer(t,
+				amember.Access{AccessID: 1, UserID: 1, ProductID: 1, BeginDate: amember.CustomTime{Time: today.AddDate(0, 0, -10)}, ExpireDate: expire},
+				amember.Access{AccessID: 2, UserID: 1, ProductID: 2, BeginDate: amember.CustomTime{Time: yesterday}, ExpireDate: expire},
+				amember.Access{AccessID: 3, UserID: 1, ProductID: 3, BeginDate: amember.CustomTime{Time: today}, ExpireDate: expire},
+				amember.Access{AccessID: 4, UserID: 1, ProductID: 4, BeginDate: amember.CustomTime{Time: today.AddDate(0, 0, 1)}, ExpireDate: expire},
+			)
+
+			ctx := context.Background()
+
+			for id := 1; id <= 4; id++ {
+				if _, err := am.RevokeAccess(ctx, id); err != nil {
+					t.Fatalf("RevokeAccess(%d): %v", id, err)
+				}
+			}
+
+			accesses, err := am.AccessesContext(ctx, amember.Params{}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			kept := map[int]amember.Access{}
+			for _, a := range accesses[1] {
+				kept[int(a.AccessID)] = a
+			}
+
+			//accesses begun before today are kept, expiring yesterday; the other ones are deleted
+			for id, want := range map[int]bool{1: true, 2: true, 3: false, 4: false} {
+
+				a, ok := kept[id]
+
+				switch {
+				case ok != want:
+					t.Errorf("access %d kept = %v, want %v", id, ok, want)
+				case ok && !a.ExpireDate.Equal(yesterday):
+					t.Errorf("access %d expires %s, want %s", id, a.ExpireDate, yesterday.Format("2006-01-02"))
+				}
+			}
+		})
+	}
+}
@@ -1,85 +1,126 @@
 package amember
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
-	"reflect"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/araddon/dateparse"
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/paperclicks/golog"
 )
 
 type Amember struct {
-	APIKey   string
-	APIURL   string
-	Gologger *golog.Golog
-	client   *http.Client
-	DB       *sql.DB
+	APIKey string
+	APIURL string
+	Logger Logger
+	client *http.Client
+	DB     *sql.DB
+	retry  RetryPolicy
+
+	dsn                 string
+	timeout             time.Duration
+	dialTimeout         time.Duration
+	tlsHandshakeTimeout time.Duration
+	userAgent           string
+	keyInQuery          bool
+
+	limiter  *rateLimiter
+	inflight semaphore
+
+	parallelism int
 }
 
 type Params struct {
 	Filter map[string]string
+	//Where adds the conditions of a Filter to the ones in Filter
+	Where  *Filter
 	Nested []string
 	Count  int
 	Page   int
+	//Order sorts the records by a field; the zero value keeps the aMember default order
+	Order Order
+	//Fields restricts the returned fields to the given ones, the other fields are left to their zero value.
+	//The field used as map key by the listing methods (e.g. login for Users) and the currency are always requested.
+	Fields []string
 }
 
-type Condition struct {
-	Column   string
-	Operator string
-	Values   []interface{}
-	SubQuery string
+// Order is the sorting of a REST collection
+type Order struct {
+	Field string
+	Desc  bool
 }
 
-func New(apiURL string, apiKey string, gl *golog.Golog) *Amember {
+// NewClient returns a client for the aMember REST API at apiURL, configured by opts.
+// Without options it uses a dedicated http.Client with a 30s dial timeout and a 10s TLS handshake timeout,
+// no database, and a NopLogger.
+func NewClient(apiURL string, apiKey string, opts ...Option) (*Amember, error) {
 
-	//create a custom timout dialer
-	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	am := &Amember{
+		APIURL:              apiURL,
+		APIKey:              apiKey,
+		dialTimeout:         30 * time.Second,
+		tlsHandshakeTimeout: 10 * time.Second,
+	}
 
-	//create a custom transport layer to use during API calls
-	tr := &http.Transport{
-		DialContext:         dialer.DialContext,
-		TLSHandshakeTimeout: 10 * time.Second,
+	for _, opt := range opts {
+		opt(am)
 	}
 
-	cli := &http.Client{Transport: tr}
+	if am.Logger == nil {
+		am.Logger = NopLogger{}
+	}
 
-	return &Amember{APIURL: apiURL, APIKey: apiKey, Gologger: gl, client: cli}
-}
+	//make sure the API key never reaches the logs
+	am.Logger = redactingLogger{Logger: am.Logger, secret: am.APIKey}
 
-func NewWithDb(apiURL string, apiKey string, dburi string, gl *golog.Golog) (*Amember, error) {
+	if am.client == nil {
 
-	//create a custom timout dialer
-	dialer := &net.Dialer{Timeout: 30 * time.Second}
+		//create a custom timout dialer
+		dialer := &net.Dialer{Timeout: am.dialTimeout}
 
-	//create a custom transport layer to use during API calls
-	tr := &http.Transport{
-		DialContext:         dialer.DialContext,
-		TLSHandshakeTimeout: 10 * time.Second,
-	}
+		//create a custom transport layer to use during API calls
+		tr := &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: am.tlsHandshakeTimeout,
+		}
 
-	cli := &http.Client{Transport: tr}
+		am.client = &http.Client{Transport: tr}
+	}
 
-	db, err := sql.Open("mysql", dburi)
-	if err != nil {
-		return nil, err
+	if am.timeout > 0 {
+		//work on a copy, a client passed with WithHTTPClient may be shared
+		cli := *am.client
+		cli.Timeout = am.timeout
+		am.client = &cli
 	}
 
-	err = db.Ping()
-	if err != nil {
-		gl.Log(err.Error(), golog.ERROR)
-		return nil, err
+	if am.DB == nil && am.dsn != "" {
+
+		db, err := sql.Open("mysql", am.dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.Ping()
+		if err != nil {
+			am.Logger.Error("connection to the aMember database failed", "error", err)
+			return nil, err
+		}
+
+		am.DB = db
 	}
 
-	return &Amember{APIURL: apiURL, APIKey: apiKey, DB: db, Gologger: gl, client: cli}, nil
+	return am, nil
 }
 
 // Memberships return a map of Membership having username as key.
@@ -94,9 +135,9 @@ func (am *Amember) MembershipsFromDB() (map[string]Membership, error) {
 
 	//get users from amember DB
 	usersQuery := `select user_id, login as username from am_user where status in
-	(1,2) and user_id in (select user_id from am_access where expire_date>= DATE_SUB(NOW(), INTERVAL 30 DAY))`
+	(?,?) and user_id in (select user_id from am_access where expire_date>= DATE_SUB(NOW(), INTERVAL 30 DAY))`
 
-	rows, err := am.DB.Query(usersQuery)
+	rows, err := am.DB.Query(usersQuery, UserActive, UserExpired)
 	if err != nil {
 		return memberships, err
 	}
@@ -106,7 +147,7 @@ func (am *Amember) MembershipsFromDB() (map[string]Membership, error) {
 		user := User{}
 		rows.Scan(&user.UserID, &user.Login)
 
-		users[user.UserID] = user
+		users[int(user.UserID)] = user
 	}
 
 	//get access from amember DB
@@ -130,7 +171,7 @@ from am_access where expire_date>= DATE_SUB(NOW(), INTERVAL 30 DAY)`
 		if err != nil {
 			return memberships, err
 		}
-		accesses[access.UserID] = append(accesses[access.UserID], access)
+		accesses[int(access.UserID)] = append(accesses[int(access.UserID)], access)
 
 	}
 
@@ -141,16 +182,16 @@ from am_access where expire_date>= DATE_SUB(NOW(), INTERVAL 30 DAY)`
 		membership.User = user
 		membership.Accesses = accesses[userID]
 
-		memberships[user.Login] = membership
+		memberships[string(user.Login)] = membership
 	}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] memberships in [%f] seconds", len(memberships), time.Since(start).Seconds()), golog.DEBUG)
+	am.Logger.Debug("returned memberships", "source", "db", "count", len(memberships), "duration", time.Since(start))
 
 	return memberships, nil
 }
 
 // UsersFromDB return a map of Users having the userID as key.
-func (am *Amember) UsersFromDB(status int, addedFrom time.Time, addedTo time.Time) (map[int]DBUser, error) {
+func (am *Amember) UsersFromDB(status UserStatus, addedFrom time.Time, addedTo time.Time) (map[int]DBUser, error) {
 
 	start := time.Now()
 
@@ -171,10 +212,10 @@ func (am *Amember) UsersFromDB(status int, addedFrom time.Time, addedTo time.Tim
 		if err != nil {
 			return users, err
 		}
-		users[user.UserID] = user
+		users[int(user.UserID)] = user
 	}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] users in [%f] seconds", len(users), time.Since(start).Seconds()), golog.DEBUG)
+	am.Logger.Debug("returned users", "source", "db", "count", len(users), "duration", time.Since(start))
 
 	return users, nil
 }
@@ -225,746 +266,921 @@ from users where email=?`
 	return user, nil
 }
 
-// UsersFromDB return a map of Users having the userID as key.
-func (am *Amember) AccessesFromDB(expiredFrom time.Time, expiredTo time.Time) (map[int][]DBAccess, error) {
+// AccessesFilter restricts the accesses returned by AccessesFromDB
+type AccessesFilter func(*AccessesQuery)
+
+// AccessesQuery collects the optional conditions of AccessesFromDB, as set by the AccessesFilter options
+type AccessesQuery struct {
+	UserIDs    []int
+	ProductIDs []int
+}
+
+// ForUsers restricts AccessesFromDB to the accesses of the given users
+func ForUsers(ids ...int) AccessesFilter {
+
+	return func(q *AccessesQuery) {
+		q.UserIDs = append(q.UserIDs, ids...)
+	}
+}
+
+// ForProducts restricts AccessesFromDB to the accesses to the given products
+func ForProducts(ids ...int) AccessesFilter {
+
+	return func(q *AccessesQuery) {
+		q.ProductIDs = append(q.ProductIDs, ids...)
+	}
+}
+
+// AccessesFromDB returns the accesses having expire_date between expiredFrom and expiredTo, both included, keyed by user_id
+// and sorted by expire date. ProductTitle is the title of the product the access is for.
+func (am *Amember) AccessesFromDB(expiredFrom time.Time, expiredTo time.Time, filters ...AccessesFilter) (map[int][]DBAccess, error) {
 
 	start := time.Now()
 
-	accesses := make(map[int][]DBAccess)
+	aq := AccessesQuery{}
+	for _, f := range filters {
+		f(&aq)
+	}
 
-	//get users from amember DB
-	query := `select coalesce(access_id,0) as access_id,
-	coalesce(invoice_id,0) as invoice_id,
-	coalesce(invoice_public_id,0) as invoice_public_id,
-	coalesce(invoice_payment_id,0) as invoice_payment_id,
-	coalesce(invoice_item_id,0) as invoice_item_id,
-	coalesce(user_id,0) as user_id,
-	coalesce(product_id,0) as product_id,
-	coalesce(transaction_id,0) as transaction_id,
-	begin_date,
-	expire_date,
-	coalesce(qty,0) as qty,
-	coalesce(comment,"") as comment
-from am_access where expire_date>= DATE_SUB(NOW(), INTERVAL 30 DAY)?`
-	rows, err := am.DB.Query(query)
+	query := `select a.access_id,
+	coalesce(a.invoice_id,0) as invoice_id,
+	a.invoice_public_id,
+	coalesce(a.invoice_payment_id,0) as invoice_payment_id,
+	coalesce(a.invoice_item_id,0) as invoice_item_id,
+	a.user_id,
+	a.product_id,
+	coalesce(a.transaction_id,'') as transaction_id,
+	a.begin_date,
+	a.expire_date,
+	coalesce(a.qty,0) as qty,
+	coalesce(a.comment,'') as comment,
+	coalesce(p.title,'') as product_title
+from am_access a
+left join am_product p on p.product_id=a.product_id
+where a.expire_date between ? and ?`
+
+	//expire_date is a DATE column, compare it with dates
+	args := []interface{}{expiredFrom.Format("2006-01-02"), expiredTo.Format("2006-01-02")}
+
+	if len(aq.UserIDs) > 0 {
+		query += fmt.Sprintf(" and a.user_id in (%s)", placeholders(len(aq.UserIDs)))
+		for _, id := range aq.UserIDs {
+			args = append(args, id)
+		}
+	}
+
+	if len(aq.ProductIDs) > 0 {
+		query += fmt.Sprintf(" and a.product_id in (%s)", placeholders(len(aq.ProductIDs)))
+		for _, id := range aq.ProductIDs {
+			args = append(args, id)
+		}
+	}
+
+	query += " order by a.user_id, a.expire_date, a.access_id"
+
+	rows, err := am.DB.Query(query, args...)
 	if err != nil {
-		return accesses, err
+		return nil, fmt.Errorf("accesses from db: %w", err)
 	}
+	defer rows.Close()
+
+	accesses := make(map[int][]DBAccess)
 
 	for rows.Next() {
 
 		access := DBAccess{}
 		err := rows.Scan(&access.AccessID, &access.InvoiceID, &access.InvoicePublicID, &access.InvoicePaymentID, &access.InvoiceItemID, &access.UserID,
-			&access.ProductID, &access.TransactionID, &access.BeginDate, &access.ExpireDate, &access.Qty, &access.Comment)
+			&access.ProductID, &access.TransactionID, &access.BeginDate, &access.ExpireDate, &access.Qty, &access.Comment, &access.ProductTitle)
 		if err != nil {
-			return accesses, err
+			return nil, fmt.Errorf("accesses from db: %w", err)
 		}
 
 		accesses[access.UserID] = append(accesses[access.UserID], access)
 	}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] accesses in [%f] seconds", len(accesses), time.Since(start).Seconds()), golog.DEBUG)
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("accesses from db: %w", err)
+	}
+
+	am.Logger.Debug("returned accesses", "source", "db", "count", len(accesses), "duration", time.Since(start))
 
 	return accesses, nil
 }
 
-// Users returns a map of User having username as key
-func (am *Amember) Users(p Params) map[string]User {
+// placeholders returns n comma separated query placeholders, for an IN condition
+func placeholders(n int) string {
 
-	start := time.Now()
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
 
-	users := make(map[string]User)
+// Users returns a map of User having username as key
+func (am *Amember) Users(p Params) map[string]User {
 
-	page := p.Page
-	count := p.Count
-	if p.Count == 0 {
-		count = 100
+	users, err := am.UsersContext(context.Background(), p)
+	if err != nil {
+		am.Logger.Error("users request failed", "error", err)
 	}
 
-	//reange over all the pages
-	for {
-		//update page value and parse params
-		p.Page = page
-		params := am.parseParams(p)
+	return users
+}
 
-		//add page param the url
-		url := fmt.Sprintf("%s/api/users?_key=%s%s", am.APIURL, am.APIKey, params)
+// UsersContext returns a map of User having username as key.
+// The crawl stops as soon as ctx is done or a page fails, and the error is returned together with the users collected so far.
+func (am *Amember) UsersContext(ctx context.Context, p Params) (map[string]User, error) {
 
-		response, err := am.doGet(url)
-		if err != nil {
-			am.Gologger.Log(err, golog.ERROR)
-			return users
-		}
+	start := time.Now()
 
-		//fmt.Printf("%#v", response)
+	users := make(map[string]User)
 
-		//perform again a marshall for every element of the response, and attempt to unmarshall into User struct
-		for k, v := range response {
+	err := am.crawl(ctx, "users", p, func(k string, v json.RawMessage) error {
 
-			if k == "_total" {
-				continue
-			}
+		u, err := decodeRecord[User](k, v)
+		if err != nil {
+			return err
+		}
 
-			m := v.(map[string]interface{})
+		users[string(u.Login)] = u
 
-			u := User{}
-			//try to parse the map into the struct fields
-			am.mapToStruct(m, &u)
+		return nil
+	})
+	if err != nil {
+		return users, err
+	}
 
-			users[u.Login] = u
-		}
+	am.Logger.Debug("returned users", "endpoint", "users", "count", len(users), "duration", time.Since(start))
 
-		if len(response) < count+1 {
-			break
-		}
+	return users, nil
+}
 
-		page++
+func (am *Amember) Invoices(p Params) map[int]Invoice {
 
+	invoices, err := am.InvoicesContext(context.Background(), p)
+	if err != nil {
+		am.Logger.Error("invoices request failed", "error", err)
 	}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] users in [%f] seconds", len(users), time.Since(start).Seconds()), golog.DEBUG)
-
-	return users
+	return invoices
 }
 
-func (am *Amember) Invoices(p Params) map[int]Invoice {
+// InvoicesContext returns a map of Invoice having invoice_id as key, including any nested record requested with p.Nested.
+// The crawl stops as soon as ctx is done or a page fails, and the error is returned together with the invoices collected so far.
+func (am *Amember) InvoicesContext(ctx context.Context, p Params) (map[int]Invoice, error) {
 
 	start := time.Now()
 
 	invoices := make(map[int]Invoice)
 
-	page := p.Page
-	count := p.Count
-	if p.Count == 0 {
-		count = 100
+	err := am.crawl(ctx, "invoices", p, func(k string, v json.RawMessage) error {
+
+		invoice, err := decodeRecord[Invoice](k, v)
+		if err != nil {
+			return err
+		}
+
+		invoices[int(invoice.InvoiceID)] = invoice
+
+		return nil
+	})
+	if err != nil {
+		return invoices, err
 	}
 
-	//reange over all the pages
-	for {
+	am.Logger.Debug("returned invoices", "endpoint", "invoices", "count", len(invoices), "duration", time.Since(start))
 
-		//update page value and parse params
-		p.Page = page
-		params := am.parseParams(p)
+	return invoices, nil
+}
 
-		//add page param the url
-		url := fmt.Sprintf("%s/api/invoices?_key=%s%s", am.APIURL, am.APIKey, params)
+// decodeRecord decodes a raw record of a REST collection into one of the models, e.g. a User for /api/users.
+// k is the key of the record in the response, used in the error messages.
+func decodeRecord[T any](k string, raw json.RawMessage) (T, error) {
 
-		response, err := am.doGet(url)
-		if err != nil {
-			am.Gologger.Log(err, golog.ERROR)
-			return invoices
-		}
+	var v T
+
+	err := json.Unmarshal(raw, &v)
+	if err != nil {
+		return v, fmt.Errorf("%T record [%s]: %w", v, k, err)
+	}
 
-		for k, v := range response {
+	return v, nil
+}
 
-			if k == "_total" {
-				continue
-			}
+// Accesses returns a map of Access slices. The map has user_id as key
+func (am *Amember) Accesses(p Params, activeOnly bool) map[int][]Access {
 
-			rawInvoice := v.(map[string]interface{})
+	accesses, err := am.AccessesContext(context.Background(), p, activeOnly)
+	if err != nil {
+		am.Logger.Error("access request failed", "error", err)
+	}
 
-			invoice := Invoice{}
-			nested := InvoiceNested{}
+	return accesses
+}
 
-			invoicePayments := []Payment{}
-			invoiceItems := []Item{}
-			invoiceAccess := []Access{}
+// AccessesContext returns a map of Access slices having user_id as key. If activeOnly=true expired accesses are skipped.
+// The crawl stops as soon as ctx is done or a page fails, and the error is returned together with the accesses collected so far.
+func (am *Amember) AccessesContext(ctx context.Context, p Params, activeOnly bool) (map[int][]Access, error) {
 
-			//try to parse the map into the struct fields
-			am.mapToStruct(rawInvoice, &invoice)
+	start := time.Now()
 
-			rawNested := rawInvoice["nested"].(map[string]interface{})
+	accesses := make(map[int][]Access)
 
-			for k2, v2 := range rawNested {
+	t := time.Now()
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 
-				switch k2 {
-				case "invoice-payments":
+	err := am.crawl(ctx, "access", p, func(k string, v json.RawMessage) error {
 
-					rawPayments := v2.([]interface{})
+		i, err := decodeRecord[Access](k, v)
+		if err != nil {
+			return err
+		}
 
-					for _, v3 := range rawPayments {
-						var payment Payment
-						m := v3.(map[string]interface{})
+		expires := time.Date(i.ExpireDate.Year(), i.ExpireDate.Month(), i.ExpireDate.Day(), 0, 0, 0, 0, i.ExpireDate.Location())
 
-						am.mapToStruct(m, &payment)
-						invoicePayments = append(invoicePayments, payment)
-					}
-				case "access":
+		//skip any expired acces if we are requesting only active ones
+		if expires.Before(today) && activeOnly {
+			return nil
+		}
 
-					rawAccess := v2.([]interface{})
+		accesses[int(i.UserID)] = append(accesses[int(i.UserID)], i)
 
-					for _, v3 := range rawAccess {
-						var access Access
-						m := v3.(map[string]interface{})
+		return nil
+	})
+	if err != nil {
+		return accesses, err
+	}
 
-						am.mapToStruct(m, &access)
-						invoiceAccess = append(invoiceAccess, access)
-					}
+	am.Logger.Debug("returned accesses", "endpoint", "access", "count", len(accesses), "duration", time.Since(start))
 
-				case "invoice-items":
+	return accesses, nil
+}
 
-					rawItems := v2.([]interface{})
+func (am *Amember) Payments(p Params) map[int]Payment {
 
-					for _, v3 := range rawItems {
-						var item Item
+	payments, err := am.PaymentsContext(context.Background(), p)
+	if err != nil {
+		am.Logger.Error("invoice-payments request failed", "error", err)
+	}
 
-						m := v3.(map[string]interface{})
+	return payments
+}
 
-						am.mapToStruct(m, &item)
-						invoiceItems = append(invoiceItems, item)
-					}
+// PaymentsContext returns a map of Payment having invoice_payment_id as key.
+// The crawl stops as soon as ctx is done or a page fails, and the error is returned together with the payments collected so far.
+func (am *Amember) PaymentsContext(ctx context.Context, p Params) (map[int]Payment, error) {
 
-				}
-			}
+	start := time.Now()
 
-			nested.InvoicePayments = invoicePayments
-			nested.Access = invoiceAccess
-			nested.InvoiceItems = invoiceItems
+	payments := make(map[int]Payment)
 
-			invoice.Nested = nested
-			invoices[invoice.InvoiceID] = invoice
-		}
+	err := am.crawl(ctx, "invoice-payments", p, func(k string, v json.RawMessage) error {
 
-		if len(response) < count+1 {
-			break
+		i, err := decodeRecord[Payment](k, v)
+		if err != nil {
+			return err
 		}
 
-		page++
+		payments[int(i.InvoicePaymentID)] = i
 
+		return nil
+	})
+	if err != nil {
+		return payments, err
 	}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] invoices in [%f] seconds", len(invoices), time.Since(start).Seconds()), golog.DEBUG)
+	am.Logger.Debug("returned payments", "endpoint", "invoice-payments", "count", len(payments), "duration", time.Since(start))
 
-	return invoices
+	return payments, nil
 }
 
-// Accesses returns a map of Access slices. The map has user_id as key
-func (am *Amember) Accesses(p Params, activeOnly bool) map[int][]Access {
-
-	start := time.Now()
+// membershipRecord is a record of /api/users, with the eventual nested block requested with _nested[]=access
+type membershipRecord struct {
+	User
+	Nested *struct {
+		Access []Access `json:"access"`
+	} `json:"nested"`
+}
 
-	accesses := make(map[int][]Access)
+// Memberships return a map of Membership having username as key.
+// If activeAccessOnly=true only accesses that have not expired yet will be attached to memberships
+func (am *Amember) Memberships(p Params, activeAccessOnly bool) map[string]Membership {
 
-	page := p.Page
-	count := p.Count
-	if p.Count == 0 {
-		count = 100
+	memberships, err := am.MembershipsContext(context.Background(), p, activeAccessOnly)
+	if err != nil {
+		am.Logger.Error("memberships request failed", "error", err)
 	}
 
-	t := time.Now()
-	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return memberships
+}
 
-	//reange over all the pages
-	for {
+// MembershipsContext return a map of Membership having username as key.
+// If activeAccessOnly=true only accesses that have not expired yet will be attached to memberships.
+// The crawl stops as soon as ctx is done or a page fails, and the error is returned together with the memberships collected so far.
+func (am *Amember) MembershipsContext(ctx context.Context, p Params, activeAccessOnly bool) (map[string]Membership, error) {
 
-		//update page value and parse params
-		p.Page = page
-		params := am.parseParams(p)
+	start := time.Now()
+	memberships := make(map[string]Membership)
 
-		//add page param the url
-		url := fmt.Sprintf("%s/api/access?_key=%s%s", am.APIURL, am.APIKey, params)
+	err := am.crawl(ctx, "users", p, func(k string, v json.RawMessage) error {
 
-		am.Gologger.Log(fmt.Sprintf("GET: %s", url), golog.DEBUG)
+		membership := Membership{}
 
-		response, err := am.doGet(url)
+		rec, err := decodeRecord[membershipRecord](k, v)
 		if err != nil {
-			am.Gologger.Log(err, golog.ERROR)
-			return accesses
+			return err
 		}
 
-		//fmt.Printf("%#v", response)
+		//parse user data and add to the current membership
+		membership.User = rec.User
 
-		//perform again a marshall for every element of the response, and attempt to unmarshall into User struct
-		for k, v := range response {
-
-			if k == "_total" {
-				continue
-			}
-
-			m := v.(map[string]interface{})
+		//if this user got no nested element, and activeAccessOnly=true skip this user
+		if rec.Nested == nil && activeAccessOnly {
+			return nil
+		}
 
-			i := Access{}
-			//try to parse the map into the struct fields
-			am.mapToStruct(m, &i)
+		//if this user got no nested element, but activeAccessOnly=false, add user and skip the rest
+		if rec.Nested == nil && !activeAccessOnly {
+			memberships[string(membership.User.Login)] = membership
+			return nil
+		}
 
-			expires := time.Date(i.ExpireDate.Year(), i.ExpireDate.Month(), i.ExpireDate.Day(), 0, 0, 0, 0, i.ExpireDate.Location())
+		//parse all access data for current user and add to the current membership
+		accesses := []Access{}
+		for _, access := range rec.Nested.Access {
 
-			//skip any expired acces if we are requesting only active ones
-			if expires.Before(today) && activeOnly {
+			//add only if access is valid (not expired)
+			if activeAccessOnly && validAccess(access) {
+				accesses = append(accesses, access)
 				continue
 			}
 
-			accesses[i.UserID] = append(accesses[i.UserID], i)
-		}
+			//otherwise add all accesses
+			accesses = append(accesses, access)
 
-		if len(response) < count+1 {
-			break
 		}
 
-		page++
+		membership.Accesses = accesses
+
+		memberships[string(membership.User.Login)] = membership
 
+		return nil
+	})
+	if err != nil {
+		return memberships, err
 	}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] accesses in [%f] seconds", len(accesses), time.Since(start).Seconds()), golog.DEBUG)
+	am.Logger.Debug("returned memberships", "endpoint", "users", "count", len(memberships), "duration", time.Since(start))
 
-	return accesses
+	return memberships, nil
 }
 
-func (am *Amember) Payments(p Params) map[int]Payment {
+// ProductCategories returns a map of products having product id as key, and the corresponding map of categories as value
+func (am *Amember) ProductCategories() map[int]map[int]int {
+
+	pc, err := am.ProductCategoriesContext(context.Background())
+	if err != nil {
+		am.Logger.Error("product-product-category request failed", "error", err)
+	}
+
+	return pc
+}
+
+// ProductCategoriesContext returns a map of products having product id as key, and the corresponding map of categories as value
+func (am *Amember) ProductCategoriesContext(ctx context.Context) (map[int]map[int]int, error) {
+
 	start := time.Now()
 
-	payments := make(map[int]Payment)
+	pc := make(map[int]map[int]int)
 
-	page := p.Page
-	count := p.Count
-	if p.Count == 0 {
-		count = 100
+	//add page param the url
+	url := am.APIURL + "/api/product-product-category"
+
+	response, err := am.doGet(ctx, url)
+	if err != nil {
+		return pc, err
 	}
 
-	//reange over all the pages
-	for {
-		//update page value and parse params
-		p.Page = page
-		params := am.parseParams(p)
+	for k, v := range response {
 
-		//add page param the url
-		url := fmt.Sprintf("%s/api/invoice-payments?_key=%s%s", am.APIURL, am.APIKey, params)
+		if k == "_total" {
+			continue
+		}
 
-		response, err := am.doGet(url)
+		prod, err := decodeRecord[[]Int](k, v)
 		if err != nil {
-			am.Gologger.Log(err, golog.ERROR)
-			return payments
+			return pc, err
 		}
 
-		//fmt.Printf("%#v", response)
+		cid, err := strconv.Atoi(k)
+		if err != nil {
+			return pc, err
+		}
 
-		//perform again a marshall for every element of the response, and attempt to unmarshall into User struct
-		for k, v := range response {
+		//range over the slice of product ids and build the final response
+		for _, pi := range prod {
 
-			if k == "_total" {
-				continue
-			}
+			id := int(pi)
 
-			m := v.(map[string]interface{})
+			//if categories map is nil, first initialize the map
+			if pc[id] == nil {
 
-			i := Payment{}
-			//try to parse the map into the struct fields
-			am.mapToStruct(m, &i)
+				pc[id] = make(map[int]int)
+			}
 
-			payments[i.InvoicePaymentID] = i
-		}
+			pc[id][cid] = cid
 
-		if len(response) < count+1 {
-			break
 		}
 
-		page++
-
 	}
+	am.Logger.Debug("returned products with categories", "endpoint", "product-product-category", "count", len(pc), "duration", time.Since(start))
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] payments in [%f] seconds", len(payments), time.Since(start).Seconds()), golog.DEBUG)
+	return pc, nil
+}
 
-	return payments
+func (am *Amember) Products(p Params) map[int]Product {
+
+	products, err := am.ProductsContext(context.Background(), p)
+	if err != nil {
+		am.Logger.Error("products request failed", "error", err)
+	}
+
+	return products
 }
 
-// Memberships return a map of Membership having username as key.
-// If activeAccessOnly=true only accesses that have not expired yet will be attached to memberships
-func (am *Amember) Memberships(p Params, activeAccessOnly bool) map[string]Membership {
+// ProductsContext returns a map of Product having product_id as key.
+// The crawl stops as soon as ctx is done or a page fails, and the error is returned together with the products collected so far.
+func (am *Amember) ProductsContext(ctx context.Context, p Params) (map[int]Product, error) {
 
 	start := time.Now()
-	memberships := make(map[string]Membership)
 
-	page := p.Page
-	count := p.Count
-	if p.Count == 0 {
-		count = 100
-	}
+	products := make(map[int]Product)
 
-	//reange over all the pages
-	for {
-		//update page value and parse params
-		p.Page = page
-		params := am.parseParams(p)
-
-		//add page param the url
-		url := fmt.Sprintf("%s/api/users?_key=%s%s", am.APIURL, am.APIKey, params)
-		fmt.Println(url)
-		response, err := am.doGet(url)
+	err := am.crawl(ctx, "products", p, func(k string, v json.RawMessage) error {
+
+		i, err := decodeRecord[Product](k, v)
 		if err != nil {
-			am.Gologger.Log(err.Error(), golog.ERROR)
-			fmt.Println(err)
-			return memberships
+			return err
 		}
 
-		//fmt.Printf("%#v", response)
+		products[int(i.ProductID)] = i
 
-		//perform again a marshall for every element of the response, and attempt to unmarshall into User struct
-		for k, v := range response {
+		return nil
+	})
+	if err != nil {
+		return products, err
+	}
 
-			membership := Membership{}
+	am.Logger.Debug("returned products", "endpoint", "products", "count", len(products), "duration", time.Since(start))
 
-			if k == "_total" {
-				continue
-			}
+	return products, nil
+}
 
-			uMap := v.(map[string]interface{})
+// crawl requests all the pages of a REST endpoint, starting from p.Page, and calls fn for every record of every page, in page order.
+// When the client parallelism is greater than 1, the pages after the first one are requested concurrently.
+// It stops at the first error returned by the API or by fn, or when ctx is done.
+func (am *Amember) crawl(ctx context.Context, endpoint string, p Params, fn func(k string, v json.RawMessage) error) error {
 
-			u := User{}
-			//parse user data and add to the current membership
-			am.mapToStruct(uMap, &u)
-			membership.User = u
+	first, err := am.fetchPage(ctx, endpoint, p)
+	if err != nil {
+		return err
+	}
 
-			//if this user got no nested element, and activeAccessOnly=true skip this user
-			if uMap["nested"] == nil && activeAccessOnly {
-				continue
-			}
+	err = first.each(fn)
+	if err != nil {
+		return fmt.Errorf("%s page %d: %w", endpoint, p.Page, err)
+	}
 
-			//if this user got no nested element, but activeAccessOnly=false, add user and skip the rest
-			if uMap["nested"] == nil && !activeAccessOnly {
-				memberships[membership.User.Login] = membership
-				continue
-			}
+	if first.last {
+		return nil
+	}
 
-			nMap := uMap["nested"].(map[string]interface{})
+	//_total tells how many pages are left, so that they can be requested in parallel
+	if am.parallelism > 1 && first.total >= 0 {
+		return am.crawlParallel(ctx, endpoint, p, first.total, fn)
+	}
 
-			aMap := nMap["access"].([]interface{})
+	//reange over all the pages
+	for {
+		p.Page++
 
-			//parse all access data for current user and add to the current membership
-			accesses := []Access{}
-			for _, a := range aMap {
-				m := a.(map[string]interface{})
-				access := Access{}
+		page, err := am.fetchPage(ctx, endpoint, p)
+		if err != nil {
+			return err
+		}
 
-				am.mapToStruct(m, &access)
+		err = page.each(fn)
+		if err != nil {
+			return fmt.Errorf("%s page %d: %w", endpoint, p.Page, err)
+		}
 
-				//add only if access is valid (not expired)
-				if activeAccessOnly && validAccess(access) {
-					accesses = append(accesses, access)
-					continue
-				}
+		if page.last {
+			break
+		}
+	}
 
-				//otherwise add all accesses
-				accesses = append(accesses, access)
+	return nil
+}
 
-			}
+// record is a single element of a REST collection page, together with its key in the response
+type record struct {
+	key   string
+	value json.RawMessage
+}
 
-			membership.Accesses = accesses
+// collectionPage is a page of a REST collection
+type collectionPage struct {
+	records []record
+	//total is the number of records of the whole collection as reported by _total, or -1 if missing
+	total int
+	//last=true means there are no more pages after this one
+	last bool
+}
 
-			memberships[membership.User.Login] = membership
+// each calls fn for every record of the page, stopping at the first error
+func (cp collectionPage) each(fn func(k string, v json.RawMessage) error) error {
 
-		}
+	for _, r := range cp.records {
 
-		if len(response) < count+1 {
-			break
+		err := fn(r.key, r.value)
+		if err != nil {
+			return err
 		}
-
-		page++
-
 	}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] memberships in [%f] seconds", len(memberships), time.Since(start).Seconds()), golog.DEBUG)
-
-	return memberships
+	return nil
 }
 
-// ProductCategories returns a map of products having product id as key, and the corresponding map of categories as value
-func (am *Amember) ProductCategories() map[int]map[int]int {
+// fetchPage requests the page p.Page of a REST endpoint and returns its records ordered by key
+func (am *Amember) fetchPage(ctx context.Context, endpoint string, p Params) (collectionPage, error) {
 
-	start := time.Now()
+	count := pageSize(p)
 
-	pc := make(map[int]map[int]int)
+	params, err := am.parseParams(endpoint, p)
+	if err != nil {
+		return collectionPage{}, fmt.Errorf("%s page %d: %w", endpoint, p.Page, err)
+	}
 
 	//add page param the url
-	url := fmt.Sprintf("%s/api/product-product-category?_key=%s", am.APIURL, am.APIKey)
+	url := fmt.Sprintf("%s/api/%s?%s", am.APIURL, endpoint, params)
 
-	response, err := am.doGet(url)
+	response, err := am.doGet(ctx, url)
 	if err != nil {
-		am.Gologger.Log(err, golog.ERROR)
-		return pc
+		return collectionPage{}, fmt.Errorf("%s page %d: %w", endpoint, p.Page, err)
 	}
 
-	//fmt.Printf("%#v", response)
-
-	//perform again a marshall for every element of the response, and attempt to unmarshall into User struct
+	records := make([]record, 0, len(response))
 	for k, v := range response {
 
 		if k == "_total" {
 			continue
 		}
 
-		prod := v.([]interface{})
+		records = append(records, record{key: k, value: v})
+	}
 
-		cid, err := strconv.Atoi(k)
-		if err != nil {
-			panic(err)
+	//aMember uses the position in the page as key, keep the same order
+	sort.Slice(records, func(i, j int) bool {
+		a, errA := strconv.Atoi(records[i].key)
+		b, errB := strconv.Atoi(records[j].key)
+		if errA != nil || errB != nil {
+			return records[i].key < records[j].key
 		}
+		return a < b
+	})
 
-		//range over the slice of product ids and build the final response
-		for _, pi := range prod {
+	am.Logger.Debug("fetched page", "endpoint", endpoint, "page", p.Page, "count", len(records))
 
-			id, err := strconv.Atoi(pi.(string))
-			if err != nil {
+	total := parseTotal(response["_total"])
 
-				panic(err)
-			}
+	//rely on _total when reported, otherwise a short page is the last one
+	last := len(records) < count
+	if total >= 0 {
+		last = (p.Page+1)*count >= total
+	}
 
-			//if categories map is nil, first initialize the map
-			if pc[id] == nil {
+	return collectionPage{records: records, total: total, last: last}, nil
+}
 
-				pc[id] = make(map[int]int)
-			}
+// Count returns the number of records of a REST collection (e.g. "users", "invoices", "access") matching the filters of p,
+// as reported by _total. Only one record is requested, so it is cheap even on large collections.
+func (am *Amember) Count(ctx context.Context, resource string, p Params) (int, error) {
 
-			pc[id][cid] = cid
+	p.Page = 0
+	p.Count = 1
 
-		}
+	page, err := am.fetchPage(ctx, resource, p)
+	if err != nil {
+		return 0, err
+	}
 
+	if page.total < 0 {
+		return 0, fmt.Errorf("%s: _total missing from response", resource)
 	}
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] products with categories in [%f] seconds", len(pc), time.Since(start).Seconds()), golog.DEBUG)
 
-	return pc
+	return page.total, nil
 }
 
-func (am *Amember) Products(p Params) map[int]Product {
-	start := time.Now()
+// pageSize returns the number of records requested per page
+func pageSize(p Params) int {
 
-	products := make(map[int]Product)
+	if p.Count > 0 {
+		return p.Count
+	}
 
-	page := p.Page
-	count := p.Count
-	if p.Count == 0 {
-		count = 100
+	return 100
+}
+
+// parseTotal converts the _total value of a response, sent either as a number or as a string, returning -1 if missing
+func parseTotal(raw json.RawMessage) int {
+
+	s, err := scalar(raw)
+	if err != nil || s == "" {
+		return -1
 	}
 
-	//reange over all the pages
-	for {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
 
-		//update page value and parse params
-		p.Page = page
-		params := am.parseParams(p)
+	return n
+}
 
-		//add page param the url
-		url := fmt.Sprintf("%s/api/products?_key=%s%s", am.APIURL, am.APIKey, params)
+// doGet requests a REST collection and returns its elements, keyed like in the response, still encoded
+func (am *Amember) doGet(ctx context.Context, url string) (map[string]json.RawMessage, error) {
 
-		response, err := am.doGet(url)
-		if err != nil {
-			am.Gologger.Log(err, golog.ERROR)
-			return products
-		}
+	response := make(map[string]json.RawMessage)
 
-		//fmt.Printf("%#v", response)
+	raw, err := am.doRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return response, err
+	}
 
-		//perform again a marshall for every element of the response, and attempt to unmarshall into User struct
-		for k, v := range response {
+	err = json.Unmarshal(raw, &response)
+	if err != nil {
+		return make(map[string]json.RawMessage), fmt.Errorf("unexpected response: %w", err)
+	}
 
-			if k == "_total" {
-				continue
-			}
+	return response, nil
+}
 
-			m := v.(map[string]interface{})
+// doRequest sends a request to the REST API, with form as url-encoded body when not nil, and returns the JSON response.
+// An aMember error payload is returned as *APIError. Transient failures are retried according to the client RetryPolicy.
+func (am *Amember) doRequest(ctx context.Context, method string, url string, form url.Values) (json.RawMessage, error) {
 
-			i := Product{}
-			//try to parse the map into the struct fields
-			am.mapToStruct(m, &i)
+	for attempt := 1; ; attempt++ {
 
-			products[i.ProductID] = i
+		response, err := am.doOnce(ctx, method, url, form)
+		if err == nil || attempt >= am.retry.MaxAttempts || !retryable(ctx, method, err) {
+			return response, err
 		}
 
-		if len(response) < count+1 {
-			break
+		wait := am.retry.backoff(attempt)
+
+		//honour the wait requested by aMember, within the limit of the policy
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.retryAfter > wait {
+			wait = apiErr.retryAfter
+			if am.retry.MaxBackoff > 0 && wait > am.retry.MaxBackoff {
+				wait = am.retry.MaxBackoff
+			}
 		}
 
-		page++
+		am.Logger.Warn("request failed, retrying", "method", method, "url", url, "attempt", attempt, "max_attempts", am.retry.MaxAttempts, "wait", wait, "error", err)
 
+		if err := sleep(ctx, wait); err != nil {
+			return response, err
+		}
 	}
+}
 
-	am.Gologger.Log(fmt.Sprintf("Returned [%d] products in [%f] seconds", len(products), time.Since(start).Seconds()), golog.DEBUG)
+// doOnce sends a single request to the REST API and returns the JSON response
+func (am *Amember) doOnce(ctx context.Context, method string, rawURL string, form url.Values) (json.RawMessage, error) {
 
-	return products
-}
+	var response json.RawMessage
+
+	am.Logger.Debug("request", "method", method, "url", rawURL)
 
-func (am *Amember) doGet(url string) (map[string]interface{}, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
 
-	response := make(map[string]interface{})
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
 
-	am.Gologger.Log(fmt.Sprintf("GET: %s", url), golog.DEBUG)
+	if err != nil {
+		return response, err
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	if am.userAgent != "" {
+		req.Header.Set("User-Agent", am.userAgent)
+	}
 
+	//the key travels in a header unless the aMember install only accepts it in the query
+	if am.keyInQuery {
+		q := req.URL.Query()
+		q.Set("_key", am.APIKey)
+		req.URL.RawQuery = q.Encode()
+	} else {
+		req.Header.Set("X-API-Key", am.APIKey)
+	}
+
+	release, err := am.throttle(ctx)
 	if err != nil {
 		return response, err
 	}
+	defer release()
 
 	resp, err := am.client.Do(req)
 
 	if err != nil {
+		//the transport errors carry the full URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
 		return response, err
 	}
 
 	defer resp.Body.Close()
 
-	err = json.NewDecoder(resp.Body).Decode(&response)
-
+	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return response, err
 	}
 
-	var responseError bool
-	var responseMessage string
+	apiErr := &APIError{StatusCode: resp.StatusCode, Method: method, Endpoint: req.URL.Path, Body: raw}
 
-	if _, ok := response["error"]; ok {
-		responseError = response["error"].(bool)
+	//only an object can be an error payload
+	var payload struct {
+		Error   json.RawMessage `json:"error"`
+		Message json.RawMessage `json:"message"`
+	}
+	isObject := len(bytes.TrimSpace(raw)) > 0 && bytes.TrimSpace(raw)[0] == '{'
+	if isObject {
+		//a record can be malformed without being an error: payload only needs the two fields
+		_ = json.Unmarshal(raw, &payload)
 	}
 
-	if _, ok := response["message"]; ok {
-		responseMessage = response["message"].(string)
+	message := errorMessage(payload.Error, payload.Message)
 
+	//a non 2xx status is an error even when the body is not a JSON error payload
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr.Message = message
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		apiErr.retryAfter = retryAfter(resp.Header.Get("Retry-After"))
+		return response, apiErr
 	}
 
-	if responseError == true {
-		return response, errors.New(responseMessage)
+	if !json.Valid(raw) {
+		return response, fmt.Errorf("invalid JSON response from %s", req.URL.Path)
 	}
 
-	return response, nil
+	if IsErrorFlag(payload.Error) {
+		apiErr.Message = message
+		return response, apiErr
+	}
+
+	return json.RawMessage(raw), nil
 }
 
-func (am *Amember) mapToStruct(m map[string]interface{}, s interface{}) {
+// IsErrorFlag reports whether the error field of a response flags an error. aMember sends true, but an error can
+// also come as a number or as its message, e.g. "error":"Invalid API key": only an absent field, false, 0, "" and null are not errors.
+func IsErrorFlag(raw json.RawMessage) bool {
 
-	//uValue := reflect.ValueOf(u)
-	elem := reflect.ValueOf(s).Elem()
+	switch strings.TrimSpace(string(raw)) {
+	case "", "false", "0", `""`, "null":
+		return false
+	}
 
-	//range over all the fields of the struct
-	for i := 0; i < elem.NumField(); i++ {
+	return true
+}
 
-		f := elem.Field(i)
-		jsonTag := elem.Type().Field(i).Tag.Get("json")
+// errorMessage returns the message of an error payload, falling back to the error field when it is a string
+func errorMessage(flag json.RawMessage, message json.RawMessage) string {
 
-		val, ok := m[jsonTag]
-		if !ok {
-			am.Gologger.Log(fmt.Sprintf("Key [%s] not found in map %#v", jsonTag, m), golog.DEBUG)
-		}
+	var m String
+	if json.Unmarshal(message, &m) == nil && m != "" {
+		return string(m)
+	}
 
-		//check inf the underlaying value of interface{} is nil.
-		//In this case the value will be ignored and the struct will have the zero value for that field
-		if val == nil {
-			continue
-		}
+	var s string
+	if json.Unmarshal(flag, &s) == nil {
+		return s
+	}
 
-		//switch on the type of the i-th struct field, and perform the right conversion
-		switch t := f.Interface().(type) {
-
-		case string:
-			f.SetString(val.(string))
-
-		case int:
-
-			kind := reflect.ValueOf(val).Kind()
-			//switch on the type of underlying value of the interface, and attempt a conversion to int
-			switch kind {
-			case reflect.String:
-				//if the string is empty leave the 0 value
-				if val.(string) == "" {
-					break
-				}
-
-				v, err := strconv.Atoi(val.(string))
-				if err != nil {
-					am.Gologger.Log(fmt.Sprintf("strconv error for field [%s]: %v", jsonTag, err), golog.ERROR)
-					break
-				}
-				f.SetInt(int64(v))
-
-			case reflect.Int:
-
-				if _, ok := val.(int); !ok {
-					am.Gologger.Log(fmt.Sprintf("assertion to int failed for field [%s]: %v", jsonTag, val), golog.ERROR)
-					break
-				}
-				f.SetInt(int64(val.(int)))
-
-			case reflect.Int32:
-				if _, ok := val.(int32); !ok {
-					am.Gologger.Log(fmt.Sprintf("assertion to int32 failed: %v", val), golog.ERROR)
-					break
-				}
-				f.SetInt(int64(val.(int32)))
-
-			case reflect.Int64:
-				if _, ok := val.(int64); !ok {
-					am.Gologger.Log(fmt.Sprintf("assertion to int64 failed: %v", val), golog.ERROR)
-					break
-				}
-				f.SetInt(int64(val.(int64)))
-
-			case reflect.Float64:
-				if _, ok := val.(float64); !ok {
-					am.Gologger.Log(fmt.Sprintf("assertion to float64 failed: %v", val), golog.ERROR)
-					break
-				}
-
-				f.SetInt(int64(val.(float64)))
-
-			default:
-				am.Gologger.Log(fmt.Sprintf("%v", reflect.ValueOf(val).Kind()), golog.DEBUG)
-			}
+	return ""
+}
 
-		case float32:
-			v, err := strconv.ParseFloat(val.(string), 32)
-			if err != nil {
-				break
-				//panic(err)
-			}
-			f.SetFloat(v)
-		case CustomTime:
-			ct := CustomTime{}
-			err := ct.UnmarshalJSON([]byte(val.(string)))
-			if err != nil {
-				am.Gologger.Log(fmt.Sprintf("Error parsing date string %s - %v", val.(string), err), golog.ERROR)
-				break
-			}
-			f.Set(reflect.ValueOf(ct))
-		case time.Time:
+// firstRecord returns the record contained in the response of a single record request.
+// aMember returns it either as a plain object or wrapped in a one element array.
+func firstRecord(raw json.RawMessage) (json.RawMessage, error) {
 
-			t, err := dateparse.ParseAny(val.(string))
-			if err != nil {
-				am.Gologger.Log(fmt.Sprintf("Error parsing date string %s - %v", val.(string), err), golog.ERROR)
-				break
-			}
+	trimmed := bytes.TrimSpace(raw)
 
-			f.Set(reflect.ValueOf(t))
-		default:
-			am.Gologger.Log(fmt.Sprintf("Type for field [%s] not found: %v", jsonTag, t), golog.DEBUG)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return trimmed, nil
+	}
 
-		}
+	var records []json.RawMessage
+
+	err := json.Unmarshal(trimmed, &records)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected response: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("empty response")
 	}
+
+	return records[0], nil
 }
 
-func (am *Amember) parseParams(p Params) string {
+// parseParams encodes p as the query string of a request to endpoint
+func (am *Amember) parseParams(endpoint string, p Params) (string, error) {
 
-	qs := ""
+	v := url.Values{}
 
 	//add all eventual filters
-	for k, v := range p.Filter {
+	for k, f := range p.Filter {
+
+		v.Add(fmt.Sprintf("_filter[%s]", k), f)
+	}
 
-		qs = fmt.Sprintf("%s&_filter[%s]=%s", qs, k, v)
+	err := p.Where.encode(v, endpointModels[endpoint])
+	if err != nil {
+		return "", err
 	}
 
 	//add all eventual nested
-	for _, v := range p.Nested {
+	for _, n := range p.Nested {
+
+		v.Add("_nested[]", n)
+	}
+
+	model := endpointModels[endpoint]
 
-		qs = fmt.Sprintf("%s&_nested[]=%s", qs, v)
+	//add eventual order and fields projection, checking the field names like the filters
+	if p.Order.Field != "" {
+
+		if model != nil && !jsonFields(model)[p.Order.Field] {
+			return "", fmt.Errorf("unknown order field [%s] for %s", p.Order.Field, model.Name())
+		}
+
+		dir := "ASC"
+		if p.Order.Desc {
+			dir = "DESC"
+		}
+
+		v.Set(fmt.Sprintf("_order[%s]", p.Order.Field), dir)
+	}
+
+	for _, f := range p.Fields {
+
+		if model != nil && !jsonFields(model)[f] {
+			return "", fmt.Errorf("unknown field [%s] for %s", f, model.Name())
+		}
+
+		v.Add("_fields[]", f)
+	}
+
+	//a projection without the map key would collapse all the records into one
+	if len(p.Fields) > 0 {
+
+		requested := make(map[string]bool)
+		for _, f := range p.Fields {
+			requested[f] = true
+		}
+
+		for _, f := range requiredFields[endpoint] {
+			if !requested[f] {
+				v.Add("_fields[]", f)
+			}
+		}
 	}
 
 	//add "page" param; if not set it starts from page=0
-	qs = fmt.Sprintf("%s&_page=%d", qs, p.Page)
+	v.Set("_page", strconv.Itoa(p.Page))
 
 	//add "count" param; if not set the default value is 100
-	if p.Count > 0 {
-		qs = fmt.Sprintf("%s&_count=%d", qs, p.Count)
-	} else {
-		qs = fmt.Sprintf("%s&_count=%d", qs, 100)
-	}
+	v.Set("_count", strconv.Itoa(pageSize(p)))
 
-	return qs
+	return v.Encode(), nil
+}
+
+// requiredFields are always requested together with Params.Fields: the field used as map key by the listing methods,
+// and the currency of the amounts of the record
+var requiredFields = map[string][]string{
+	"users":            {"login"},
+	"invoices":         {"invoice_id", "currency"},
+	"invoice-payments": {"invoice_payment_id", "currency"},
+	"access":           {"user_id"},
+	"products":         {"product_id"},
 }
 
 func validAccess(a Access) bool {
@@ -998,26 +1214,26 @@ func (am *Amember) ExpiredUsers(expiredSince int) map[string]User {
 		excludeUser := false
 		var expired time.Time
 
-		for _, a := range accesses[u.UserID] {
+		for _, a := range accesses[int(u.UserID)] {
 
 			//if the expire_date of at least one access is earlier than expiredSince, then break the foreach here. This user must not be added to the list
-			if time.Since(a.ExpireDate).Hours() < float64(expiredSince*24) {
+			if time.Since(a.ExpireDate.Time).Hours() < float64(expiredSince*24) {
 				excludeUser = true
 				break
 			}
 
 			//get the last access
 			if a.ExpireDate.After(expired) {
-				expired = a.ExpireDate
+				expired = a.ExpireDate.Time
 			}
 
 		}
 
 		if !excludeUser {
 			u.ExpiredAt.Time = expired
-			expiredUsers[u.Login] = u
+			expiredUsers[string(u.Login)] = u
 
-			am.Gologger.Log(fmt.Sprintf("Adding user to expired list: [username: %s] [expired: %s]  [days: %f]", u.Login, expired.Format("2006-01-02"), time.Since(expired).Hours()/24), golog.INFO)
+			am.Logger.Info("adding user to expired list", "username", u.Login, "expired", expired.Format("2006-01-02"), "days", time.Since(expired).Hours()/24)
 		}
 	}
 	return expiredUsers
@@ -1061,7 +1277,7 @@ func (am *Amember) PaymentsByDate(datetime time.Time, itemTitle string, itemDesc
 			userID      int
 			username    string
 			paymentdate sql.NullTime
-			amount      float32
+			amount      Money
 		)
 
 		err := rows.Scan(&userID, &username, &paymentdate, &amount)
@@ -1070,9 +1286,9 @@ func (am *Amember) PaymentsByDate(datetime time.Time, itemTitle string, itemDesc
 		}
 
 		payment := Payment{}
-		payment.Username = username
+		payment.Username = String(username)
 		payment.Amount = amount
-		payment.Dattm = paymentdate.Time
+		payment.Dattm = CustomTime{paymentdate.Time}
 
 		paymets[username] = payment
 
@@ -1114,7 +1330,7 @@ func (am *Amember) RefundsByDate(datetime time.Time, itemTitle string) (map[stri
 			userID     int
 			username   string
 			refundDate sql.NullTime
-			amount     float32
+			amount     Money
 		)
 
 		err := rows.Scan(&userID, &username, &refundDate, &amount)
@@ -1123,9 +1339,9 @@ func (am *Amember) RefundsByDate(datetime time.Time, itemTitle string) (map[stri
 		}
 
 		payment := Payment{}
-		payment.Username = username
+		payment.Username = String(username)
 		payment.RefundAmount = amount
-		payment.RefundDattm = refundDate.Time
+		payment.RefundDattm = CustomTime{refundDate.Time}
 
 		paymets[username] = payment
 
@@ -1133,12 +1349,28 @@ func (am *Amember) RefundsByDate(datetime time.Time, itemTitle string) (map[stri
 	return paymets, nil
 }
 
-// UsersFromDB return a map of Users having the userID as key.
+// ViewLimit is the number of users GetUsersFromView returns at most when called with no limit
+const ViewLimit = 10000
+
+// GetUsersFromView returns at most limit users of the users view satisfying all the conditions, or at most ViewLimit
+// of them when limit <= 0. See BuildWhereConditions for the conditions, and QueryUsersView to read the whole view.
 func (am *Amember) GetUsersFromView(conditions []Condition, limit int) ([]ViewUser, error) {
 
-	users := []ViewUser{}
+	if limit <= 0 {
+		limit = ViewLimit
+	}
+
+	return am.QueryUsersView(conditions, QueryOptions{Limit: limit})
+}
+
+// QueryUsersView returns the users of the users view satisfying all the conditions, sorted and paginated by opts.
+// See BuildWhereConditions for the conditions.
+func (am *Amember) QueryUsersView(conditions []Condition, opts QueryOptions) ([]ViewUser, error) {
 
-	whereConditions, conditionValues := BuildWhereConditions(conditions, 10000)
+	whereConditions, conditionValues, err := BuildWhereConditions("users", conditions, opts)
+	if err != nil {
+		return nil, fmt.Errorf("users view: %w", err)
+	}
 
 	//get users from amember DB
 	usersQuery := fmt.Sprintf(`select userId,
@@ -1168,11 +1400,13 @@ func (am *Amember) GetUsersFromView(conditions []Condition, limit int) ([]ViewUs
 	last_updated
 from users %s`, whereConditions)
 
-	//test
 	rows, err := am.DB.Query(usersQuery, conditionValues...)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("users view: %w", err)
 	}
+	defer rows.Close()
+
+	users := []ViewUser{}
 
 	for rows.Next() {
 
@@ -1183,102 +1417,16 @@ from users %s`, whereConditions)
 			&user.TotalPayments, &user.FirstPayment, &user.LastPayment, &user.HowDidYouHear, &user.PreferredContactMethod, &user.PreferredContact,
 			&user.PaymentsLast3Months, &user.IsTopPayingUser, &user.CancellationDate, &user.LastUpdated)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("users view: %w", err)
 		}
 
 		users = append(users, user)
 	}
 
-	return users, nil
-}
-
-// SelectQuery gets in input a Model and a slice of Condition, and returns a parametrized query and a slice of values to pass to the Exec method
-func BuildWhereConditions(conditions []Condition, limit int) (string, []interface{}) {
-
-	//whereConditions is a slice with already formated pieces of conditions
-	//these conditions will be finally joined with "AND" operator
-	whereConditions := []string{}
-
-	//keeps track of the total number of params in the final query
-	paramsIndex := 1
-
-	values := []interface{}{}
-
-	limitCondition := ""
-
-	if limit > 0 {
-		limitCondition = fmt.Sprintf("LIMIT %d", limit)
-	}
-
-	//if conditions are empty return a query without where conditions, and empty values
-	if len(conditions) == 0 {
-		q := fmt.Sprintf("WHERE ? %s", limitCondition)
-
-		values = append(values, true)
-
-		return q, values
-	}
-
-	//for each element in the conditions map
-	//check if the lenght of the values for the condition
-	//if there is only 1 value then it is a simple operator (=,=>, <=, !=)
-	//if the length is 2 or higher it might be one of IN, BETWEEN operators
-	for _, v := range conditions {
-
-		switch v.Operator {
-		case "IN":
-
-			//if a subquery was passed for the IN condition the simply append "column IN (subquery)"
-			if v.SubQuery != "" {
-
-				whereConditions = append(whereConditions, fmt.Sprintf("%s IN (%s)", v.Column, v.SubQuery))
-				break
-			}
-
-			params := []string{}
-			//add a list of params for the IN condition in the form of $1, $2, $3...
-			for _, val := range v.Values {
-
-				params = append(params, "?")
-				values = append(values, val)
-				paramsIndex++
-			}
-			//append "column IN ($1, $2, $3,...)"
-			whereConditions = append(whereConditions, fmt.Sprintf("%s %s (%s)", v.Column, v.Operator, strings.Join(params, ",")))
-
-		case "BETWEEN":
-
-			//append "column BETWEEN $1 AND $2"
-			whereConditions = append(whereConditions, fmt.Sprintf("%s %s $? AND ?", v.Column, v.Operator))
-
-			values = append(values, v.Values[0])
-			values = append(values, v.Values[1])
-
-			//increase index by 2 because 2 values were used here
-			paramsIndex = paramsIndex + 2
-
-		case "=", ">", "<", "<=", ">=", "<>", "!=":
-
-			whereConditions = append(whereConditions, fmt.Sprintf("%s %s ?", v.Column, v.Operator))
-
-			values = append(values, v.Values[0])
-
-			paramsIndex++
-		case "LIKE":
-
-			whereConditions = append(whereConditions, fmt.Sprintf("%s %s ?", v.Column, v.Operator))
-
-			values = append(values, v.Values[0])
-
-			paramsIndex++
-
-		default:
-			fmt.Printf("Missing or unknown operator: %s\n", v.Operator)
-		}
-
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("users view: %w", err)
 	}
 
-	q := fmt.Sprintf("WHERE %s %s", strings.Join(whereConditions, " AND "), limitCondition)
-
-	return q, values
+	return users, nil
 }
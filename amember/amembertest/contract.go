@@ -0,0 +1,214 @@
+package amembertest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/paperclicks/gomember/amember"
+)
+
+// contractModels maps the REST collections, and the nested blocks, to the model their records decode into
+var contractModels = map[string]reflect.Type{
+	"users":            reflect.TypeOf(amember.User{}),
+	"invoices":         reflect.TypeOf(amember.Invoice{}),
+	"invoice-items":    reflect.TypeOf(amember.InvoiceItem{}),
+	"invoice-payments": reflect.TypeOf(amember.Payment{}),
+	"access":           reflect.TypeOf(amember.Access{}),
+	"products":         reflect.TypeOf(amember.Product{}),
+}
+
+// CheckContract checks every fixture saved in dir with CheckFixture, and returns all the problems found.
+// Run it in a test against fixtures recorded from the aMember install, to catch the changes of field types
+// and the new fields of an aMember upgrade:
+//
+//	func TestAmemberContract(t *testing.T) {
+//		if err := amembertest.CheckContract("testdata/amember"); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+func CheckContract(dir string) error {
+
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(fixtures) == 0 {
+		return fmt.Errorf("amembertest: no fixtures in %s", dir)
+	}
+
+	var errs []error
+	for _, f := range fixtures {
+		errs = append(errs, f.Check())
+	}
+
+	return errors.Join(errs...)
+}
+
+// Check decodes every record of the response into its model, and fails on the fields the model does not have
+// and on the values that cannot be decoded into the type of their field. Error responses are not checked.
+func (f Fixture) Check() error {
+
+	path, _, _ := strings.Cut(f.URL, "?")
+	collection, _, _ := strings.Cut(strings.Trim(strings.TrimPrefix(path, "/api/"), "/"), "/")
+
+	if f.Status != 200 || isError(f.Body) {
+		return nil
+	}
+
+	if collection == "product-product-category" {
+
+		categories, err := responseRecords(f.Body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.URL, err)
+		}
+
+		var errs []error
+		for k, raw := range categories {
+
+			var products []amember.Int
+
+			err := json.Unmarshal(raw, &products)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s [%s]: %w", f.URL, k, err))
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	model, ok := contractModels[collection]
+	if !ok {
+		return fmt.Errorf("%s: no model for %s", f.URL, collection)
+	}
+
+	records, err := responseRecords(f.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.URL, err)
+	}
+
+	var errs []error
+	for k, raw := range records {
+		errs = append(errs, checkRecord(model, collection, raw, fmt.Sprintf("%s [%s]", f.URL, k)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// responseRecords returns the records of a collection page, keyed like in the response, or of a single record response
+func responseRecords(body json.RawMessage) (map[string]json.RawMessage, error) {
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(body, &list); err == nil {
+
+		records := make(map[string]json.RawMessage, len(list))
+		for i, raw := range list {
+			records[fmt.Sprint(i)] = raw
+		}
+
+		return records, nil
+	}
+
+	var records map[string]json.RawMessage
+
+	err := json.Unmarshal(body, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(records, "_total")
+
+	return records, nil
+}
+
+// checkRecord checks a record, and the records of its nested blocks, against model
+func checkRecord(model reflect.Type, collection string, raw json.RawMessage, where string) error {
+
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(raw, &fields)
+	if err != nil {
+		return fmt.Errorf("%s: %w", where, err)
+	}
+
+	byTag := make(map[string]reflect.StructField)
+	for i := 0; i < model.NumField(); i++ {
+		if tag, _, _ := strings.Cut(model.Field(i).Tag.Get("json"), ","); tag != "" && tag != "-" {
+			byTag[tag] = model.Field(i)
+		}
+	}
+
+	var errs []error
+
+	for k, v := range fields {
+
+		if k == "nested" {
+			errs = append(errs, checkNested(v, where))
+			continue
+		}
+
+		field, ok := byTag[k]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown field %s of %s: %s", where, k, collection, v))
+			continue
+		}
+
+		err := json.Unmarshal(v, reflect.New(field.Type).Interface())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: field %s of %s: cannot decode %s into %s: %w", where, k, collection, v, field.Type, err))
+		}
+	}
+
+	//the whole record must decode too, e.g. currencies are propagated to the amounts
+	if len(errs) == 0 {
+		err := json.Unmarshal(raw, reflect.New(model).Interface())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", where, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkNested checks the records of the nested blocks of a record
+func checkNested(raw json.RawMessage, where string) error {
+
+	var blocks map[string][]json.RawMessage
+
+	err := json.Unmarshal(raw, &blocks)
+	if err != nil {
+		return fmt.Errorf("%s: nested: %w", where, err)
+	}
+
+	var errs []error
+
+	for name, records := range blocks {
+
+		model, ok := contractModels[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown nested block %s", where, name))
+			continue
+		}
+
+		for i, r := range records {
+			errs = append(errs, checkRecord(model, name, r, fmt.Sprintf("%s nested[%s][%d]", where, name, i)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isError reports whether body is an aMember error payload
+func isError(body json.RawMessage) bool {
+
+	var e struct {
+		Error json.RawMessage `json:"error"`
+	}
+
+	_ = json.Unmarshal(body, &e)
+
+	return amember.IsErrorFlag(e.Error)
+}
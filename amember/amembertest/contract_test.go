@@ -0,0 +1,234 @@
+package amembertest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paperclicks/gomember/amember"
+)
+
+// fixturesDir holds fixtures in the shapes of the responses of a real aMember install: numbers as strings,
+// zero dates, options as a string or an empty list. They are written by hand, not recorded from Server,
+// so that TestContract checks the models against aMember and not against themselves.
+const fixturesDir = "testdata/amember"
+
+func TestContract(t *testing.T) {
+
+	err := CheckContract(fixturesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures, err := LoadFixtures(fixturesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//every endpoint used by the amember package must have a fixture
+	endpoints := map[string]bool{"users": false, "invoices": false, "access": false, "invoice-payments": false, "products": false, "product-product-category": false}
+
+	for _, f := range fixtures {
+		path, _, _ := strings.Cut(f.URL, "?")
+		collection, _, _ := strings.Cut(strings.TrimPrefix(path, "/api/"), "/")
+		endpoints[collection] = true
+	}
+
+	for e, ok := range endpoints {
+		if !ok {
+			t.Errorf("no fixture for /api/%s", e)
+		}
+	}
+}
+
+func TestContractFailures(t *testing.T) {
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "unknown field", body: `{"0":{"user_id":"1","login":"a","new_field":"x"},"_total":1}`, want: "unknown field new_field"},
+		{name: "undecodable value", body: `{"0":{"user_id":"1","login":"a","added":"yesterday"},"_total":1}`, want: "field added"},
+		{name: "unknown nested field", body: `{"0":{"user_id":"1","login":"a","nested":{"access":[{"access_id":"1","new_field":"x"}]}},"_total":1}`, want: "new_field"},
+	}
+
+	for _, tt := range tests {
+
+		f := Fixture{Method: http.MethodGet, URL: "/api/users", Status: http.StatusOK, Body: []byte(tt.body)}
+
+		err := f.Check()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Check() = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	//aMember flags errors with true, a number or the message itself
+	for _, body := range []string{`{"error":true,"message":"Not Found"}`, `{"error":1,"message":"Not Found"}`, `{"error":"Invalid API key"}`} {
+
+		f := Fixture{Method: http.MethodGet, URL: "/api/users", Status: http.StatusOK, Body: []byte(body)}
+		if err := f.Check(); err != nil {
+			t.Errorf("Check() of the error response %s = %v, want nil", body, err)
+		}
+	}
+}
+
+func TestRecorderRedacts(t *testing.T) {
+
+	users := []amember.User{
+		{Login: "jdoe", Pass: "$2y$10$secret", Email: "jdoe@example.com", NameF: "John", NameL: "Doe", Phone: "+39 055 123456",
+			Street: "Via Roma 1", City: "Firenze", Zip: "50100", Country: "IT", TaxID: "IT01234567890", RememberKey: "remember-me", LastIP: "10.0.0.1"},
+		{Login: "asmith", Email: "asmith@example.com", NameF: "Anna", NameL: "Smith", Country: "IT"},
+		{Login: "bsmith", Email: "bsmith@example.com", NameF: "Bob", NameL: "Smith", Country: "IT"},
+		{Login: "cnobody", Email: "cnobody@example.com", Country: "IT"},
+		{Login: "dnobody", Email: "dnobody@example.com", Country: "IT"},
+	}
+
+	srv := NewServer(WithFixtures(Fixtures{
+		Users:    users,
+		Invoices: []amember.Invoice{{UserID: 1, Currency: "EUR", InvoiceKey: "secret-key"}},
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	am, err := srv.Client(amember.WithHTTPClient(&http.Client{Transport: NewRecorder(dir, srv.Server.Client().Transport)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	if _, err := am.UsersContext(ctx, amember.Params{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := am.InvoicesContext(ctx, amember.Params{}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("%d fixtures recorded, want 2", len(files))
+	}
+
+	u := users[0]
+	secrets := []string{DefaultKey, string(u.Pass), string(u.Email), string(u.Login), string(u.NameF), string(u.NameL), string(u.Phone),
+		string(u.Street), string(u.City), string(u.Zip), string(u.TaxID), string(u.RememberKey), string(u.LastIP), "secret-key"}
+
+	for _, file := range files {
+
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range secrets {
+			if strings.Contains(string(b), s) {
+				t.Errorf("%s contains %q", filepath.Base(file), s)
+			}
+		}
+	}
+
+	//the users are keyed by login: each one must come back on replay, under the pseudonym of its login
+	am, err = amember.NewClient("http://amember.test", "key", amember.WithHTTPClient(&http.Client{Transport: NewReplayer(dir)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := am.UsersContext(ctx, amember.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replayed) != len(users) {
+		t.Errorf("%d users replayed, want %d", len(replayed), len(users))
+	}
+
+	for _, u := range users {
+
+		got, ok := replayed[Pseudonym(string(u.Login))]
+		if !ok || got.Email != amember.String(Pseudonym(string(u.Email))) || got.Country != u.Country {
+			t.Errorf("user %s replayed as %+v, want its pseudonymized record", u.Login, got)
+		}
+	}
+}
+
+func TestPseudonym(t *testing.T) {
+
+	if Pseudonym("jdoe") != Pseudonym("jdoe") {
+		t.Error("Pseudonym is not stable")
+	}
+
+	if Pseudonym("jdoe") == Pseudonym("jdoe2") {
+		t.Error("distinct values have the same pseudonym")
+	}
+
+	if !strings.HasPrefix(Pseudonym("jdoe"), Redacted+"-") {
+		t.Errorf("Pseudonym(jdoe) = %s, want the %s prefix", Pseudonym("jdoe"), Redacted)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+
+	am, err := amember.NewClient("http://amember.test", "key", amember.WithHTTPClient(&http.Client{Transport: NewReplayer(fixturesDir)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	users, err := am.UsersContext(ctx, amember.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users) != 3 {
+		t.Errorf("UsersContext() returned %d users, want 3", len(users))
+	}
+
+	u, ok := users[Pseudonym("lbianchi")]
+	if !ok || u.UserID != 2 || u.Status != amember.UserExpired || u.IsLocked != amember.AutoLockDisabled || !u.AffAdded.IsZero() || !bool(u.Unsubscribed) {
+		t.Errorf("UsersContext()[lbianchi] = %+v, want the expired user 2", u)
+	}
+
+	invoice, err := am.InvoiceContext(ctx, 1, "invoice-items", "invoice-payments", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := amember.Money{Amount: 4900, Currency: "EUR"}
+	if invoice.FirstTotal != price || invoice.Status != amember.InvoiceRecurringActive || len(invoice.Nested.InvoicePayments) != 2 || len(invoice.Nested.Access) != 2 {
+		t.Errorf("InvoiceContext(1) = %+v, want the recurring invoice with 2 payments", invoice)
+	}
+
+	invoices, err := am.InvoicesContext(ctx, amember.Params{Nested: []string{"invoice-items", "invoice-payments", "access"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var options amember.ItemOptions
+	for _, inv := range invoices {
+		for _, it := range inv.Nested.InvoiceItems {
+			if it.InvoiceItemID == 3 {
+				options = it.Options
+			}
+		}
+	}
+
+	if color, _ := options["color"].(map[string]interface{}); color["value"] != "red" {
+		t.Errorf("options of item 3 = %v, want the red color", options)
+	}
+
+	_, err = am.InvoiceContext(ctx, 2)
+	if err == nil {
+		t.Error("InvoiceContext(2) without fixture: want an error")
+	}
+}
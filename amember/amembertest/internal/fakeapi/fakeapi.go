@@ -0,0 +1,668 @@
+// Package fakeapi is an in-process implementation of the subset of the aMember REST API used by the amember package.
+// Records are kept as decoded JSON objects, and served with the same shapes aMember uses: collections keyed by the
+// position in the page plus _total, nested blocks, single records wrapped in an array, and error payloads.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Record is a record of a collection, as decoded from JSON
+type Record map[string]interface{}
+
+// collections maps the REST collections to the field holding their ID
+var collections = map[string]string{
+	"users":            "user_id",
+	"invoices":         "invoice_id",
+	"invoice-items":    "invoice_item_id",
+	"invoice-payments": "invoice_payment_id",
+	"access":           "access_id",
+	"products":         "product_id",
+}
+
+// relation is a nested block: the records of collection having foreignKey equal to the ID of the parent
+type relation struct {
+	collection string
+	foreignKey string
+}
+
+// nestedRelations maps a collection and the name of a nested block to the records it contains
+var nestedRelations = map[string]map[string]relation{
+	"invoices": {
+		"invoice-items":    {collection: "invoice-items", foreignKey: "invoice_id"},
+		"invoice-payments": {collection: "invoice-payments", foreignKey: "invoice_id"},
+		"access":           {collection: "access", foreignKey: "invoice_id"},
+	},
+	"users": {
+		"access":           {collection: "access", foreignKey: "user_id"},
+		"invoices":         {collection: "invoices", foreignKey: "user_id"},
+		"invoice-payments": {collection: "invoice-payments", foreignKey: "user_id"},
+	},
+}
+
+// dateColumns are the DATE columns: aMember sends them without time, and compares them with dates
+var dateColumns = map[string]bool{
+	"begin_date":       true,
+	"expire_date":      true,
+	"rebill_date":      true,
+	"start_date":       true,
+	"start_date_fixed": true,
+}
+
+// Store holds the records served by Handler. It is safe for concurrent use.
+type Store struct {
+	mu         sync.RWMutex
+	records    map[string][]Record
+	nextID     map[string]int
+	categories map[int][]int
+}
+
+// NewStore returns an empty Store
+func NewStore() *Store {
+
+	return &Store{records: make(map[string][]Record), nextID: make(map[string]int), categories: make(map[int][]int)}
+}
+
+// Add adds a record to a collection. A record without ID, or with ID 0, gets the next free one.
+// It returns the ID of the record.
+func (s *Store) Add(collection string, r Record) (int, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(collection, r)
+}
+
+// AddJSON adds a record, encoded as a JSON object, to a collection. It returns the ID of the record.
+func (s *Store) AddJSON(collection string, b []byte) (int, error) {
+
+	r := Record{}
+
+	err := json.Unmarshal(b, &r)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.Add(collection, r)
+}
+
+// SetProductCategories sets the products of a product category, as served by /api/product-product-category
+func (s *Store) SetProductCategories(categoryID int, productIDs []int) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.categories[categoryID] = productIDs
+}
+
+// Records returns a copy of the records of a collection, in insertion order
+func (s *Store) Records(collection string) []Record {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Record, 0, len(s.records[collection]))
+	for _, r := range s.records[collection] {
+		out = append(out, copyRecord(r))
+	}
+
+	return out
+}
+
+func (s *Store) add(collection string, r Record) (int, error) {
+
+	idField, ok := collections[collection]
+	if !ok {
+		return 0, fmt.Errorf("unknown collection %s", collection)
+	}
+
+	r = copyRecord(r)
+
+	//nested blocks are stored in their own collections
+	delete(r, "nested")
+
+	id, _ := strconv.Atoi(Scalar(r[idField]))
+	if id == 0 {
+		id = s.nextID[collection] + 1
+	}
+
+	if id > s.nextID[collection] {
+		s.nextID[collection] = id
+	}
+
+	r[idField] = strconv.Itoa(id)
+
+	for k, v := range r {
+		if t, ok := v.(string); ok && dateColumns[k] && len(t) > 10 {
+			r[k] = t[:10]
+		}
+	}
+
+	s.records[collection] = append(s.records[collection], r)
+
+	return id, nil
+}
+
+// find returns the index of the record of a collection having the given ID, or -1
+func (s *Store) find(collection string, id string) int {
+
+	idField := collections[collection]
+
+	for i, r := range s.records[collection] {
+		if Scalar(r[idField]) == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Handler serves the REST API from the store. Requests must carry key either as the X-API-Key header or
+// as the _key query parameter.
+func (s *Store) Handler(key string) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if k := r.Header.Get("X-API-Key"); k != key && r.URL.Query().Get("_key") != key {
+			WriteError(w, http.StatusOK, "API Error 10002 - [key] is not found or disabled")
+			return
+		}
+
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+		collection, id, _ := strings.Cut(path, "/")
+
+		if collection == "product-product-category" && r.Method == http.MethodGet {
+			s.serveCategories(w)
+			return
+		}
+
+		if _, ok := collections[collection]; !ok {
+			WriteError(w, http.StatusNotFound, fmt.Sprintf("API Error 404 - [%s] not found", collection))
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			s.serveList(w, r, collection)
+		case r.Method == http.MethodGet:
+			s.serveRecord(w, r, collection, id)
+		case r.Method == http.MethodPost && id == "":
+			s.serveInsert(w, r, collection)
+		case r.Method == http.MethodPut && id != "":
+			s.serveUpdate(w, r, collection, id)
+		case r.Method == http.MethodDelete && id != "":
+			s.serveDelete(w, collection, id)
+		default:
+			WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
+
+func (s *Store) serveList(w http.ResponseWriter, r *http.Request, collection string) {
+
+	q := r.URL.Query()
+
+	filters, err := parseFilters(q)
+	if err != nil {
+		WriteError(w, http.StatusOK, err.Error())
+		return
+	}
+
+	page, _ := strconv.Atoi(q.Get("_page"))
+	count, err := strconv.Atoi(q.Get("_count"))
+	if err != nil || count <= 0 {
+		count = 20
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matching := []Record{}
+	for _, rec := range s.records[collection] {
+		if filters.match(rec) {
+			matching = append(matching, rec)
+		}
+	}
+
+	sortRecords(matching, q)
+
+	response := map[string]interface{}{"_total": len(matching)}
+
+	for i := page * count; i < len(matching) && i < (page+1)*count; i++ {
+		response[strconv.Itoa(i-page*count)] = s.render(collection, matching[i], q)
+	}
+
+	WriteJSON(w, http.StatusOK, response)
+}
+
+func (s *Store) serveRecord(w http.ResponseWriter, r *http.Request, collection string, id string) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.find(collection, id)
+	if i < 0 {
+		WriteError(w, http.StatusOK, fmt.Sprintf("API Error 10003 - record [%s] not found", id))
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, []interface{}{s.render(collection, s.records[collection][i], r.URL.Query())})
+}
+
+func (s *Store) serveInsert(w http.ResponseWriter, r *http.Request, collection string) {
+
+	err := r.ParseForm()
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rec, nested := parseForm(r.PostForm)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	//the ID is always assigned by aMember
+	delete(rec, collections[collection])
+
+	id, err := s.add(collection, rec)
+	if err != nil {
+		WriteError(w, http.StatusOK, err.Error())
+		return
+	}
+
+	//nested records are inserted with the foreign key pointing to the new record
+	q := url.Values{}
+	for name, children := range nested {
+
+		rel, ok := nestedRelations[collection][name]
+		if !ok {
+			continue
+		}
+
+		q.Add("_nested[]", name)
+
+		for _, child := range children {
+
+			delete(child, collections[rel.collection])
+			child[rel.foreignKey] = strconv.Itoa(id)
+
+			//nested records belong to the same user of the parent
+			if uid, ok := rec["user_id"]; ok {
+				if _, ok := child["user_id"]; !ok {
+					child["user_id"] = uid
+				}
+			}
+
+			if _, err := s.add(rel.collection, child); err != nil {
+				WriteError(w, http.StatusOK, err.Error())
+				return
+			}
+		}
+	}
+
+	i := s.find(collection, strconv.Itoa(id))
+
+	WriteJSON(w, http.StatusOK, []interface{}{s.render(collection, s.records[collection][i], q)})
+}
+
+func (s *Store) serveUpdate(w http.ResponseWriter, r *http.Request, collection string, id string) {
+
+	err := r.ParseForm()
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fields, _ := parseForm(r.PostForm)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(collection, id)
+	if i < 0 {
+		WriteError(w, http.StatusOK, fmt.Sprintf("API Error 10003 - record [%s] not found", id))
+		return
+	}
+
+	rec := s.records[collection][i]
+	for k, v := range fields {
+
+		//the ID cannot change
+		if k == collections[collection] {
+			continue
+		}
+
+		if t, ok := v.(string); ok && dateColumns[k] && len(t) > 10 {
+			v = t[:10]
+		}
+
+		rec[k] = v
+	}
+
+	WriteJSON(w, http.StatusOK, []interface{}{s.render(collection, rec, nil)})
+}
+
+func (s *Store) serveDelete(w http.ResponseWriter, collection string, id string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(collection, id)
+	if i < 0 {
+		WriteError(w, http.StatusOK, fmt.Sprintf("API Error 10003 - record [%s] not found", id))
+		return
+	}
+
+	rec := s.records[collection][i]
+	s.records[collection] = append(s.records[collection][:i], s.records[collection][i+1:]...)
+
+	WriteJSON(w, http.StatusOK, []interface{}{rec})
+}
+
+func (s *Store) serveCategories(w http.ResponseWriter) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	response := map[string]interface{}{"_total": len(s.categories)}
+
+	for cid, products := range s.categories {
+
+		ids := make([]string, 0, len(products))
+		for _, p := range products {
+			ids = append(ids, strconv.Itoa(p))
+		}
+
+		response[strconv.Itoa(cid)] = ids
+	}
+
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// render returns a copy of a record restricted to the requested _fields[], with the requested _nested[] blocks
+func (s *Store) render(collection string, rec Record, q url.Values) Record {
+
+	out := copyRecord(rec)
+
+	if fields := q["_fields[]"]; len(fields) > 0 {
+
+		keep := make(map[string]bool)
+		for _, f := range fields {
+			keep[f] = true
+		}
+
+		for k := range out {
+			if !keep[k] {
+				delete(out, k)
+			}
+		}
+	}
+
+	nested := Record{}
+	for _, name := range q["_nested[]"] {
+
+		rel, ok := nestedRelations[collection][name]
+		if !ok {
+			continue
+		}
+
+		id := Scalar(rec[collections[collection]])
+
+		children := []interface{}{}
+		for _, child := range s.records[rel.collection] {
+			if Scalar(child[rel.foreignKey]) == id {
+				children = append(children, copyRecord(child))
+			}
+		}
+
+		nested[name] = children
+	}
+
+	if len(nested) > 0 {
+		out["nested"] = nested
+	}
+
+	return out
+}
+
+// parseForm converts the form of an insert or update into a record and its nested records,
+// encoded as nested[name][index][field]
+func parseForm(form url.Values) (Record, map[string][]Record) {
+
+	rec := Record{}
+	nested := map[string][]Record{}
+
+	re := regexp.MustCompile(`^nested\[([^\]]+)\]\[(\d+)\]\[([^\]]+)\]$`)
+
+	indexes := map[string]map[int]Record{}
+
+	for k, v := range form {
+
+		m := re.FindStringSubmatch(k)
+		if m == nil {
+			rec[k] = v[0]
+			continue
+		}
+
+		i, _ := strconv.Atoi(m[2])
+
+		if indexes[m[1]] == nil {
+			indexes[m[1]] = map[int]Record{}
+		}
+		if indexes[m[1]][i] == nil {
+			indexes[m[1]][i] = Record{}
+		}
+
+		indexes[m[1]][i][m[3]] = v[0]
+	}
+
+	for name, byIndex := range indexes {
+
+		keys := make([]int, 0, len(byIndex))
+		for i := range byIndex {
+			keys = append(keys, i)
+		}
+		sort.Ints(keys)
+
+		for _, i := range keys {
+			nested[name] = append(nested[name], byIndex[i])
+		}
+	}
+
+	return rec, nested
+}
+
+// condition is a _filter[field] or _filter[field][op] parameter
+type condition struct {
+	field string
+	op    string
+	value string
+}
+
+type conditions []condition
+
+var filterKey = regexp.MustCompile(`^_filter\[([^\]]+)\](?:\[([^\]]+)\])?$`)
+
+func parseFilters(q url.Values) (conditions, error) {
+
+	var cs conditions
+
+	for k, values := range q {
+
+		m := filterKey.FindStringSubmatch(k)
+		if m == nil {
+			continue
+		}
+
+		switch m[2] {
+		case "", "=", "<>", "!=", ">", ">=", "<", "<=", "LIKE":
+		default:
+			return nil, fmt.Errorf("API Error 10010 - unknown filter operator [%s]", m[2])
+		}
+
+		for _, v := range values {
+			cs = append(cs, condition{field: m[1], op: m[2], value: v})
+		}
+	}
+
+	return cs, nil
+}
+
+// match reports whether rec satisfies all the conditions
+func (cs conditions) match(rec Record) bool {
+
+	for _, c := range cs {
+		if !Match(rec, c.field, c.op, c.value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match reports whether the field of rec satisfies the condition "field op value", with op one of
+// =, <>, !=, >, >=, <, <=, LIKE. An empty op means =.
+func Match(rec Record, field string, op string, value string) bool {
+
+	v := Scalar(rec[field])
+
+	switch strings.ToUpper(op) {
+	case "", "=":
+		return v == value
+	case "<>", "!=":
+		return v != value
+	case "LIKE":
+		return like(v, value)
+	case ">":
+		return compare(v, value) > 0
+	case ">=":
+		return compare(v, value) >= 0
+	case "<":
+		return compare(v, value) < 0
+	case "<=":
+		return compare(v, value) <= 0
+	}
+
+	return false
+}
+
+// sortRecords sorts the records by the _order[field] parameter, or by ID
+func sortRecords(records []Record, q url.Values) {
+
+	for k, v := range q {
+
+		m := regexp.MustCompile(`^_order\[([^\]]+)\]$`).FindStringSubmatch(k)
+		if m == nil {
+			continue
+		}
+
+		desc := strings.EqualFold(v[0], "DESC")
+
+		sort.SliceStable(records, func(i, j int) bool {
+			cmp := compare(Scalar(records[i][m[1]]), Scalar(records[j][m[1]]))
+			if desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+
+		return
+	}
+}
+
+// compare compares two values numerically when they are both numbers, as strings otherwise
+func compare(a string, b string) int {
+
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(a, b)
+}
+
+// like matches v against a case insensitive SQL LIKE pattern
+func like(v string, pattern string) bool {
+
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	return regexp.MustCompile(sb.String()).MatchString(v)
+}
+
+// Scalar returns the text of a JSON scalar the way aMember compares it: bools as 1/0, null as ""
+func Scalar(v interface{}) string {
+
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		if t {
+			return "1"
+		}
+		return "0"
+	case json.Number:
+		return t.String()
+	}
+
+	return fmt.Sprint(v)
+}
+
+func copyRecord(r Record) Record {
+
+	out := make(Record, len(r))
+	for k, v := range r {
+		out[k] = v
+	}
+
+	return out
+}
+
+// WriteJSON writes v as the JSON response body
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes an aMember error payload
+func WriteError(w http.ResponseWriter, status int, message string) {
+
+	WriteJSON(w, status, map[string]interface{}{"error": true, "message": message})
+}
@@ -0,0 +1,365 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const key = "secret"
+
+// newStore returns a store holding 5 users, the user i having user_id i and login ui
+func newStore(t *testing.T) *Store {
+
+	s := NewStore()
+
+	for i := 1; i <= 5; i++ {
+		_, err := s.Add("users", Record{"user_id": fmt.Sprint(i), "login": fmt.Sprintf("u%d", i), "email": fmt.Sprintf("u%d@example.com", i), "status": float64(i % 2)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return s
+}
+
+// do sends a request to the handler of s and decodes the JSON response
+func do(t *testing.T, s *Store, method string, target string, form url.Values) interface{} {
+
+	t.Helper()
+
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	req.Header.Set("X-API-Key", key)
+
+	w := httptest.NewRecorder()
+	s.Handler(key).ServeHTTP(w, req)
+
+	var v interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("%s %s: %v: %s", method, target, err, w.Body.String())
+	}
+
+	return v
+}
+
+// list returns the values of field of the records of a collection page, in page order, and its _total
+func list(t *testing.T, v interface{}, field string) ([]string, int) {
+
+	t.Helper()
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		t.Fatalf("response %v is not a collection page", v)
+	}
+
+	if m["error"] != nil {
+		t.Fatalf("error response %v", m)
+	}
+
+	var out []string
+	for i := 0; ; i++ {
+
+		rec, ok := m[fmt.Sprint(i)].(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		out = append(out, Scalar(rec[field]))
+	}
+
+	return out, int(m["_total"].(float64))
+}
+
+// isError reports whether v is an aMember error payload
+func isError(v interface{}) bool {
+
+	m, ok := v.(map[string]interface{})
+
+	return ok && m["error"] == true
+}
+
+func TestKey(t *testing.T) {
+
+	s := newStore(t)
+
+	tests := []struct {
+		header string
+		query  string
+		ok     bool
+	}{
+		{header: key, ok: true},
+		{query: key, ok: true},
+		{header: "wrong", query: key, ok: true},
+		{header: "wrong"},
+		{query: "wrong"},
+		{},
+	}
+
+	for _, tt := range tests {
+
+		target := "/api/users"
+		if tt.query != "" {
+			target += "?_key=" + tt.query
+		}
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if tt.header != "" {
+			req.Header.Set("X-API-Key", tt.header)
+		}
+
+		w := httptest.NewRecorder()
+		s.Handler(key).ServeHTTP(w, req)
+
+		var v interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+			t.Fatal(err)
+		}
+
+		if isError(v) == tt.ok {
+			t.Errorf("header %q, query %q: response %v, want ok %v", tt.header, tt.query, v, tt.ok)
+		}
+
+		//aMember reports a wrong key with a 200
+		if !tt.ok && (w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "[key]")) {
+			t.Errorf("header %q, query %q: %d %s, want a 200 [key] error", tt.header, tt.query, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+
+	s := newStore(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"1", "2", "3", "4", "5"}},
+		{query: "_filter[login]=u2", want: []string{"2"}},
+		{query: "_filter[login][%3D]=u2", want: []string{"2"}},
+		{query: "_filter[user_id][>]=3", want: []string{"4", "5"}},
+		{query: "_filter[user_id][>%3D]=3", want: []string{"3", "4", "5"}},
+		{query: "_filter[user_id][<]=2", want: []string{"1"}},
+		{query: "_filter[user_id][<%3D]=2", want: []string{"1", "2"}},
+		{query: "_filter[user_id][<>]=1&_filter[user_id][!%3D]=5", want: []string{"2", "3", "4"}},
+		{query: "_filter[email][LIKE]=U_@%25", want: []string{"1", "2", "3", "4", "5"}},
+		{query: "_filter[email][LIKE]=%254@example.com", want: []string{"4"}},
+		{query: "_filter[status]=1&_filter[user_id][>]=2", want: []string{"3", "5"}},
+		{query: "_filter[login]=nobody", want: nil},
+	}
+
+	for _, tt := range tests {
+
+		got, total := list(t, do(t, s, http.MethodGet, "/api/users?"+tt.query, nil), "user_id")
+
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("%s: got %v, _total %d, want %v", tt.query, got, total, tt.want)
+		}
+	}
+
+	if v := do(t, s, http.MethodGet, "/api/users?_filter[login][REGEXP]=u", nil); !isError(v) {
+		t.Errorf("unknown operator: %v, want an error", v)
+	}
+}
+
+func TestOrderAndPaging(t *testing.T) {
+
+	s := newStore(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "_count=2", want: []string{"1", "2"}},
+		{query: "_count=2&_page=2", want: []string{"5"}},
+		{query: "_count=2&_page=3", want: nil},
+		{query: "_order[user_id]=DESC&_count=2&_page=1", want: []string{"3", "2"}},
+		{query: "_order[user_id]=asc&_count=3", want: []string{"1", "2", "3"}},
+		{query: "_order[status]=desc&_count=5", want: []string{"1", "3", "5", "2", "4"}},
+	}
+
+	for _, tt := range tests {
+
+		got, total := list(t, do(t, s, http.MethodGet, "/api/users?"+tt.query, nil), "user_id")
+
+		if !reflect.DeepEqual(got, tt.want) || total != 5 {
+			t.Errorf("%s: got %v, _total %d, want %v, _total 5", tt.query, got, total, tt.want)
+		}
+	}
+
+	//numbers are ordered as numbers
+	for i := 6; i <= 10; i++ {
+		s.Add("users", Record{"login": fmt.Sprintf("u%d", i)})
+	}
+
+	got, _ := list(t, do(t, s, http.MethodGet, "/api/users?_order[user_id]=DESC&_count=3", nil), "user_id")
+	if !reflect.DeepEqual(got, []string{"10", "9", "8"}) {
+		t.Errorf("numeric order: got %v, want [10 9 8]", got)
+	}
+}
+
+func TestFields(t *testing.T) {
+
+	s := newStore(t)
+
+	v := do(t, s, http.MethodGet, "/api/users?_fields[]=login&_fields[]=email&_count=1", nil)
+
+	rec := v.(map[string]interface{})["0"].(map[string]interface{})
+	if len(rec) != 2 || rec["login"] != "u1" || rec["email"] != "u1@example.com" {
+		t.Errorf("_fields[]: got %v, want login and email only", rec)
+	}
+
+	v = do(t, s, http.MethodGet, "/api/users/2?_fields[]=login", nil)
+
+	recs := v.([]interface{})
+	if rec := recs[0].(map[string]interface{}); len(rec) != 1 || rec["login"] != "u2" {
+		t.Errorf("_fields[] of a single record: got %v, want login only", recs)
+	}
+}
+
+func TestNestedInsert(t *testing.T) {
+
+	s := newStore(t)
+
+	form := url.Values{
+		"user_id":                              {"2"},
+		"currency":                             {"EUR"},
+		"invoice_id":                           {"99"},
+		"nested[invoice-items][0][item_id]":    {"3"},
+		"nested[invoice-items][1][item_id]":    {"4"},
+		"nested[access][0][product_id]":        {"3"},
+		"nested[access][0][begin_date]":        {"2024-03-01 10:00:00"},
+		"nested[invoice-payments][0][amount]":  {"49.00"},
+		"nested[invoice-payments][0][user_id]": {"2"},
+	}
+
+	v := do(t, s, http.MethodPost, "/api/invoices", form)
+
+	inv := v.([]interface{})[0].(map[string]interface{})
+
+	//the ID is assigned by the store, not taken from the form
+	if inv["invoice_id"] != "1" || inv["user_id"] != "2" {
+		t.Fatalf("inserted invoice %v, want invoice_id 1 of user 2", inv)
+	}
+
+	nested := inv["nested"].(map[string]interface{})
+
+	items := nested["invoice-items"].([]interface{})
+	if len(items) != 2 || items[0].(map[string]interface{})["item_id"] != "3" || items[1].(map[string]interface{})["item_id"] != "4" {
+		t.Errorf("invoice-items %v, want items 3 and 4 in form order", items)
+	}
+
+	access := nested["access"].([]interface{})[0].(map[string]interface{})
+	if access["invoice_id"] != "1" || access["user_id"] != "2" || access["access_id"] != "1" || access["begin_date"] != "2024-03-01" {
+		t.Errorf("access %v, want access 1 of invoice 1 and user 2, beginning 2024-03-01", access)
+	}
+
+	//the nested records are stored in their collections, and read back as nested blocks
+	got, _ := list(t, do(t, s, http.MethodGet, "/api/access?_filter[user_id]=2", nil), "access_id")
+	if !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("accesses of user 2: %v, want [1]", got)
+	}
+
+	v = do(t, s, http.MethodGet, "/api/users/2?_nested[]=invoices&_nested[]=access&_nested[]=unknown", nil)
+
+	nested = v.([]interface{})[0].(map[string]interface{})["nested"].(map[string]interface{})
+	if len(nested) != 2 || len(nested["invoices"].([]interface{})) != 1 || len(nested["access"].([]interface{})) != 1 {
+		t.Errorf("nested blocks of user 2: %v, want one invoice and one access", nested)
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+
+	s := newStore(t)
+
+	s.Add("access", Record{"user_id": "1", "product_id": "3", "begin_date": "2024-03-01", "expire_date": "2024-04-01"})
+
+	v := do(t, s, http.MethodPut, "/api/access/1", url.Values{"expire_date": {"2024-05-01 12:00:00"}, "access_id": {"7"}})
+
+	rec := v.([]interface{})[0].(map[string]interface{})
+	if rec["expire_date"] != "2024-05-01" || rec["access_id"] != "1" || rec["product_id"] != "3" {
+		t.Errorf("updated access %v, want expire_date 2024-05-01 and the other fields unchanged", rec)
+	}
+
+	if v := do(t, s, http.MethodPut, "/api/access/2", url.Values{"expire_date": {"2024-05-01"}}); !isError(v) {
+		t.Errorf("update of a missing record: %v, want an error", v)
+	}
+
+	v = do(t, s, http.MethodDelete, "/api/users/3", nil)
+	if rec := v.([]interface{})[0].(map[string]interface{}); rec["login"] != "u3" {
+		t.Errorf("deleted user %v, want u3", rec)
+	}
+
+	if got, _ := list(t, do(t, s, http.MethodGet, "/api/users", nil), "user_id"); !reflect.DeepEqual(got, []string{"1", "2", "4", "5"}) {
+		t.Errorf("users after delete: %v, want [1 2 4 5]", got)
+	}
+
+	for _, target := range []string{"/api/users/3", "/api/nope"} {
+		if v := do(t, s, http.MethodGet, target, nil); !isError(v) || !strings.Contains(fmt.Sprint(v), "not found") {
+			t.Errorf("GET %s: %v, want a not found error", target, v)
+		}
+	}
+
+	if v := do(t, s, http.MethodDelete, "/api/users", nil); !isError(v) {
+		t.Errorf("DELETE of a collection: %v, want an error", v)
+	}
+}
+
+func TestCategories(t *testing.T) {
+
+	s := newStore(t)
+	s.SetProductCategories(1, []int{3, 4})
+	s.SetProductCategories(2, []int{4})
+
+	v := do(t, s, http.MethodGet, "/api/product-product-category", nil)
+
+	want := map[string]interface{}{"_total": float64(2), "1": []interface{}{"3", "4"}, "2": []interface{}{"4"}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("product-product-category: %v, want %v", v, want)
+	}
+}
+
+func TestMatch(t *testing.T) {
+
+	rec := Record{"n": float64(10), "s": "Hello World", "b": true, "null": nil}
+
+	tests := []struct {
+		field string
+		op    string
+		value string
+		want  bool
+	}{
+		{field: "n", value: "10", want: true},
+		{field: "n", op: ">", value: "9", want: true},
+		{field: "n", op: "<", value: "9"},
+		{field: "n", op: ">=", value: "10", want: true},
+		{field: "n", op: "<=", value: "10.5", want: true},
+		{field: "n", op: "!=", value: "10"},
+		{field: "s", op: "like", value: "hello%", want: true},
+		{field: "s", op: "LIKE", value: "%o_w%", want: true},
+		{field: "s", op: "LIKE", value: "hello"},
+		{field: "s", op: "LIKE", value: "h.llo%"},
+		{field: "s", op: ">", value: "Hello", want: true},
+		{field: "b", value: "1", want: true},
+		{field: "null", value: "", want: true},
+		{field: "missing", op: "<>", value: "x", want: true},
+		{field: "n", op: "REGEXP", value: "1"},
+	}
+
+	for _, tt := range tests {
+		if got := Match(rec, tt.field, tt.op, tt.value); got != tt.want {
+			t.Errorf("Match(%s %s %q) = %v, want %v", tt.field, tt.op, tt.value, got, tt.want)
+		}
+	}
+}
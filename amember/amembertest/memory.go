@@ -0,0 +1,717 @@
+package amembertest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paperclicks/gomember/amember"
+	"github.com/paperclicks/gomember/amember/amembertest/internal/fakeapi"
+)
+
+// dateFormat is the format of the DATE columns
+const dateFormat = "2006-01-02"
+
+// memoryAPIURL and memoryAPIKey address the in-process REST API of a Memory client
+const (
+	memoryAPIURL = "http://amember.memory"
+	memoryAPIKey = "memory"
+)
+
+// Fixtures are the records a Memory client starts with
+type Fixtures struct {
+	Users []amember.User
+	//Invoices are stored together with the records of their nested block
+	Invoices []amember.Invoice
+	Accesses []amember.Access
+	Payments []amember.Payment
+	Products []amember.Product
+	//ProductCategories maps a product category ID to the IDs of its products
+	ProductCategories map[int][]int
+}
+
+// Memory is an amember.Client that keeps its records in memory, for tests and local development.
+//
+// The REST methods are the ones of *amember.Amember, talking to an in-process copy of the aMember REST API:
+// filters, nested blocks, ordering, pagination and errors behave as they do against aMember, and the
+// records written through the client are visible to the following reads. The DB methods compute their
+// results from the same records.
+type Memory struct {
+	*amember.Amember
+	store *fakeapi.Store
+}
+
+var _ amember.Client = (*Memory)(nil)
+
+// NewMemory returns a Memory client seeded with f. Records without ID get the next free one.
+// opts configure the underlying *amember.Amember, e.g. amember.WithLogger or amember.WithParallelism; options about the
+// HTTP transport and the database are overridden.
+func NewMemory(f Fixtures, opts ...amember.Option) (*Memory, error) {
+
+	store := fakeapi.NewStore()
+
+	m := &Memory{store: store}
+
+	for _, u := range f.Users {
+		if err := m.seed("users", u); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range f.Products {
+		if err := m.seed("products", p); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range f.Accesses {
+		if err := m.seed("access", a); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range f.Payments {
+		if err := m.seed("invoice-payments", p); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, inv := range f.Invoices {
+		if err := m.seedInvoice(inv); err != nil {
+			return nil, err
+		}
+	}
+
+	for cid, products := range f.ProductCategories {
+		store.SetProductCategories(cid, products)
+	}
+
+	client := &http.Client{Transport: handlerTransport{handler: store.Handler(memoryAPIKey)}}
+
+	am, err := amember.NewClient(memoryAPIURL, memoryAPIKey, append(opts, amember.WithHTTPClient(client), amember.WithDB(nil), amember.WithDSN(""))...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Amember = am
+
+	return m, nil
+}
+
+// Handler returns the in-process REST API serving the records of m to the requests carrying key,
+// as the X-API-Key header or the _key query parameter. The records written through it are visible to m.
+func (m *Memory) Handler(key string) http.Handler {
+
+	return m.store.Handler(key)
+}
+
+// seed adds a fixture to a collection of the store, encoded the way aMember sends it
+func (m *Memory) seed(collection string, v interface{}) error {
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("seed %s: %w", collection, err)
+	}
+
+	_, err = m.store.AddJSON(collection, b)
+	if err != nil {
+		return fmt.Errorf("seed %s: %w", collection, err)
+	}
+
+	return nil
+}
+
+// seedInvoice adds an invoice, and the records of its nested block pointing to it
+func (m *Memory) seedInvoice(inv amember.Invoice) error {
+
+	nested := inv.Nested
+	inv.Nested = amember.InvoiceNested{}
+
+	b, err := json.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("seed invoices: %w", err)
+	}
+
+	id, err := m.store.AddJSON("invoices", b)
+	if err != nil {
+		return fmt.Errorf("seed invoices: %w", err)
+	}
+
+	for _, it := range nested.InvoiceItems {
+		it.InvoiceID = amember.Int(id)
+		if err := m.seed("invoice-items", it); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range nested.InvoicePayments {
+		p.InvoiceID = amember.Int(id)
+		if p.UserID == 0 {
+			p.UserID = inv.UserID
+		}
+		if err := m.seed("invoice-payments", p); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range nested.Access {
+		a.InvoiceID = amember.Int(id)
+		if a.UserID == 0 {
+			a.UserID = inv.UserID
+		}
+		if err := m.seed("access", a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handlerTransport is an http.RoundTripper serving the requests with an http.Handler, without network
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+// memoryRecords decodes all the records of a collection of the store
+func memoryRecords[T any](m *Memory, collection string) ([]T, error) {
+
+	var out []T
+
+	for _, r := range m.store.Records(collection) {
+
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var v T
+
+		err = json.Unmarshal(b, &v)
+		if err != nil {
+			return nil, fmt.Errorf("%T record [%s]: %w", v, fakeapi.Scalar(r[collectionIDs[collection]]), err)
+		}
+
+		out = append(out, v)
+	}
+
+	return out, nil
+}
+
+// collectionIDs maps the collections read by the DB methods of Memory to the field holding their ID
+var collectionIDs = map[string]string{
+	"users":            "user_id",
+	"access":           "access_id",
+	"invoice-items":    "invoice_item_id",
+	"invoice-payments": "invoice_payment_id",
+	"products":         "product_id",
+}
+
+// MembershipsFromDB returns the memberships of the active and expired users having an access expired in the last 30 days.
+// As with the database, the users only have UserID and Login set.
+func (m *Memory) MembershipsFromDB() (map[string]amember.Membership, error) {
+
+	memberships := make(map[string]amember.Membership)
+
+	users, err := memoryRecords[amember.User](m, "users")
+	if err != nil {
+		return memberships, err
+	}
+
+	accesses, err := memoryRecords[amember.Access](m, "access")
+	if err != nil {
+		return memberships, err
+	}
+
+	since := time.Now().AddDate(0, 0, -30)
+
+	byUser := make(map[int][]amember.Access)
+	for _, a := range accesses {
+		if !a.ExpireDate.Before(since) {
+			byUser[int(a.UserID)] = append(byUser[int(a.UserID)], a)
+		}
+	}
+
+	for _, u := range users {
+
+		if u.Status != amember.UserActive && u.Status != amember.UserExpired {
+			continue
+		}
+
+		if len(byUser[int(u.UserID)]) == 0 {
+			continue
+		}
+
+		memberships[string(u.Login)] = amember.Membership{User: amember.User{UserID: u.UserID, Login: u.Login}, Accesses: byUser[int(u.UserID)]}
+	}
+
+	return memberships, nil
+}
+
+// UsersFromDB returns the users having the given status, added between addedFrom and addedTo, keyed by user_id
+func (m *Memory) UsersFromDB(status amember.UserStatus, addedFrom time.Time, addedTo time.Time) (map[int]amember.DBUser, error) {
+
+	users := make(map[int]amember.DBUser)
+
+	all, err := memoryRecords[amember.User](m, "users")
+	if err != nil {
+		return users, err
+	}
+
+	for _, u := range all {
+
+		if u.Status != status || u.Added.Before(addedFrom) || u.Added.After(addedTo) {
+			continue
+		}
+
+		users[int(u.UserID)] = amember.DBUser{
+			UserID: int(u.UserID),
+			Login:  string(u.Login),
+			NameF:  string(u.NameF),
+			NameL:  string(u.NameL),
+			Email:  string(u.Email),
+			Status: u.Status,
+			Added:  nullTime(u.Added.Time),
+		}
+	}
+
+	return users, nil
+}
+
+// AccessesFromDB returns the accesses having expire_date between expiredFrom and expiredTo, both included, keyed by user_id
+// and sorted by expire date
+func (m *Memory) AccessesFromDB(expiredFrom time.Time, expiredTo time.Time, filters ...amember.AccessesFilter) (map[int][]amember.DBAccess, error) {
+
+	aq := amember.AccessesQuery{}
+	for _, f := range filters {
+		f(&aq)
+	}
+
+	all, err := memoryRecords[amember.Access](m, "access")
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := memoryRecords[amember.Product](m, "products")
+	if err != nil {
+		return nil, err
+	}
+
+	titles := make(map[amember.Int]amember.String)
+	for _, p := range products {
+		titles[p.ProductID] = p.Title
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].ExpireDate.Before(all[j].ExpireDate.Time) })
+
+	accesses := make(map[int][]amember.DBAccess)
+
+	for _, a := range all {
+
+		//like the query of AccessesFromDB, compare the calendar dates of the bounds with the DATE column
+		if expire := a.ExpireDate.Format(dateFormat); expire < expiredFrom.Format(dateFormat) || expire > expiredTo.Format(dateFormat) {
+			continue
+		}
+
+		if (len(aq.UserIDs) > 0 && !containsID(aq.UserIDs, int(a.UserID))) || (len(aq.ProductIDs) > 0 && !containsID(aq.ProductIDs, int(a.ProductID))) {
+			continue
+		}
+
+		accesses[int(a.UserID)] = append(accesses[int(a.UserID)], amember.DBAccess{
+			AccessID:         int(a.AccessID),
+			InvoiceID:        int(a.InvoiceID),
+			InvoicePublicID:  sql.NullString{String: string(a.InvoicePublicID), Valid: a.InvoicePublicID != ""},
+			InvoicePaymentID: int(a.InvoicePaymentID),
+			InvoiceItemID:    int(a.InvoiceItemID),
+			UserID:           int(a.UserID),
+			ProductID:        int(a.ProductID),
+			TransactionID:    string(a.TransactionID),
+			BeginDate:        nullTime(a.BeginDate.Time),
+			ExpireDate:       nullTime(a.ExpireDate.Time),
+			Qty:              int(a.Qty),
+			Comment:          string(a.Comment),
+			ProductTitle:     string(titles[a.ProductID]),
+		})
+	}
+
+	return accesses, nil
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []int, id int) bool {
+
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PaymentsByDate returns the payments made on the day of datetime, not refunded, of invoices having an item whose title
+// contains itemTitle, and whose title contains itemTitle or whose description contains itemDescription. They are keyed by username.
+func (m *Memory) PaymentsByDate(datetime time.Time, itemTitle string, itemDescription string) (map[string]amember.Payment, error) {
+
+	payments := make(map[string]amember.Payment)
+
+	err := m.eachPayment(func(p amember.Payment, u amember.User, items []amember.InvoiceItem) {
+
+		if p.Amount.Amount <= 0 || !p.RefundAmount.IsZero() || !sameDay(p.Dattm.Time, datetime) {
+			return
+		}
+
+		for _, it := range items {
+			if contains(it.ItemTitle, itemTitle) && (contains(it.ItemTitle, itemTitle) || contains(it.ItemDescription, itemDescription)) {
+				payments[string(u.Login)] = amember.Payment{Username: u.Login, Amount: p.Amount, Dattm: p.Dattm}
+				return
+			}
+		}
+	})
+
+	return payments, err
+}
+
+// RefundsByDate returns the refunds made on the day of datetime, of invoices having an item whose title or description
+// contains itemTitle. They are keyed by username.
+func (m *Memory) RefundsByDate(datetime time.Time, itemTitle string) (map[string]amember.Payment, error) {
+
+	refunds := make(map[string]amember.Payment)
+
+	err := m.eachPayment(func(p amember.Payment, u amember.User, items []amember.InvoiceItem) {
+
+		if p.RefundAmount.Amount <= 0 || !sameDay(p.RefundDattm.Time, datetime) {
+			return
+		}
+
+		for _, it := range items {
+			if contains(it.ItemTitle, itemTitle) || contains(it.ItemDescription, itemTitle) {
+				refunds[string(u.Login)] = amember.Payment{Username: u.Login, RefundAmount: p.RefundAmount, RefundDattm: p.RefundDattm}
+				return
+			}
+		}
+	})
+
+	return refunds, err
+}
+
+// eachPayment calls fn for each payment, together with its user and the items of its invoice
+func (m *Memory) eachPayment(fn func(p amember.Payment, u amember.User, items []amember.InvoiceItem)) error {
+
+	payments, err := memoryRecords[amember.Payment](m, "invoice-payments")
+	if err != nil {
+		return err
+	}
+
+	users, err := memoryRecords[amember.User](m, "users")
+	if err != nil {
+		return err
+	}
+
+	items, err := memoryRecords[amember.InvoiceItem](m, "invoice-items")
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[amember.Int]amember.User)
+	for _, u := range users {
+		byID[u.UserID] = u
+	}
+
+	byInvoice := make(map[amember.Int][]amember.InvoiceItem)
+	for _, it := range items {
+		byInvoice[it.InvoiceID] = append(byInvoice[it.InvoiceID], it)
+	}
+
+	for _, p := range payments {
+		fn(p, byID[p.UserID], byInvoice[p.InvoiceID])
+	}
+
+	return nil
+}
+
+// GetUserFromView returns the user having the given email, as in the users view.
+// An empty ViewUser is returned when there is no such user.
+func (m *Memory) GetUserFromView(email string) (amember.ViewUser, error) {
+
+	users, err := m.viewUsers()
+	if err != nil {
+		return amember.ViewUser{}, err
+	}
+
+	for _, u := range users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return amember.ViewUser{}, nil
+}
+
+// GetUsersFromView returns at most limit users of the users view satisfying all the conditions,
+// or at most amember.ViewLimit of them when limit <= 0
+func (m *Memory) GetUsersFromView(conditions []amember.Condition, limit int) ([]amember.ViewUser, error) {
+
+	if limit <= 0 {
+		limit = amember.ViewLimit
+	}
+
+	return m.QueryUsersView(conditions, amember.QueryOptions{Limit: limit})
+}
+
+// QueryUsersView returns the users of the users view satisfying all the conditions, sorted and paginated by opts.
+// Conditions and options are validated by BuildWhereConditions, as they are for the database.
+func (m *Memory) QueryUsersView(conditions []amember.Condition, opts amember.QueryOptions) ([]amember.ViewUser, error) {
+
+	_, _, err := amember.BuildWhereConditions("users", conditions, opts)
+	if err != nil {
+		return nil, fmt.Errorf("users view: %w", err)
+	}
+
+	users, err := m.viewUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	type row struct {
+		user amember.ViewUser
+		rec  fakeapi.Record
+	}
+
+	rows := []row{}
+
+	for _, u := range users {
+
+		rec := viewRecord(u)
+
+		if matchConditions(rec, "AND", conditions) {
+			rows = append(rows, row{user: u, rec: rec})
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+
+		for _, o := range opts.OrderBy {
+
+			a, b := rows[i].rec, rows[j].rec
+			if o.Desc {
+				a, b = b, a
+			}
+
+			if fakeapi.Match(a, o.Field, "<", fakeapi.Scalar(b[o.Field])) {
+				return true
+			}
+
+			if fakeapi.Match(b, o.Field, "<", fakeapi.Scalar(a[o.Field])) {
+				return false
+			}
+		}
+
+		return false
+	})
+
+	out := []amember.ViewUser{}
+
+	for i := opts.Offset; i < len(rows) && (opts.Limit == 0 || i < opts.Offset+opts.Limit); i++ {
+		out = append(out, rows[i].user)
+	}
+
+	return out, nil
+}
+
+// viewUsers computes the rows of the users view from the users, their accesses and their payments
+func (m *Memory) viewUsers() ([]amember.ViewUser, error) {
+
+	users, err := memoryRecords[amember.User](m, "users")
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := memoryRecords[amember.Access](m, "access")
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := memoryRecords[amember.Payment](m, "invoice-payments")
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := memoryRecords[amember.Product](m, "products")
+	if err != nil {
+		return nil, err
+	}
+
+	titles := make(map[amember.Int]amember.String)
+	for _, p := range products {
+		titles[p.ProductID] = p.Title
+	}
+
+	out := make([]amember.ViewUser, 0, len(users))
+
+	for _, u := range users {
+
+		v := amember.ViewUser{
+			UserID:      int(u.UserID),
+			Username:    string(u.Login),
+			FirstName:   string(u.NameF),
+			LastName:    string(u.NameL),
+			Email:       string(u.Email),
+			SignupDate:  nullTime(u.Added.Time),
+			MobilePhone: string(u.Phone),
+		}
+
+		//the product of the view is the one of the access expiring last
+		for _, a := range accesses {
+			if a.UserID == u.UserID && (!v.ExpirationDate.Valid || a.ExpireDate.After(v.ExpirationDate.Time)) {
+				v.ExpirationDate = nullTime(a.ExpireDate.Time)
+				v.ProductName = string(titles[a.ProductID])
+			}
+		}
+
+		for _, p := range payments {
+
+			if p.UserID != u.UserID || p.Amount.Amount <= 0 {
+				continue
+			}
+
+			if total, err := v.TotalPayments.Add(p.Amount); err == nil {
+				v.TotalPayments = total
+			}
+
+			if !v.FirstPayment.Valid || p.Dattm.Before(v.FirstPayment.Time) {
+				v.FirstPayment = nullTime(p.Dattm.Time)
+			}
+
+			if !v.LastPayment.Valid || p.Dattm.After(v.LastPayment.Time) {
+				v.LastPayment = nullTime(p.Dattm.Time)
+			}
+		}
+
+		out = append(out, v)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID < out[j].UserID })
+
+	return out, nil
+}
+
+// matchConditions reports whether rec satisfies the conditions joined by op, the way BuildWhereConditions does in SQL.
+// The conditions must have been validated by BuildWhereConditions.
+func matchConditions(rec fakeapi.Record, op string, conditions []amember.Condition) bool {
+
+	for _, c := range conditions {
+
+		ok := matchCondition(rec, c)
+
+		if op == "OR" && ok {
+			return true
+		}
+
+		if op != "OR" && !ok {
+			return false
+		}
+	}
+
+	return op != "OR"
+}
+
+func matchCondition(rec fakeapi.Record, c amember.Condition) bool {
+
+	op := strings.ToUpper(strings.Join(strings.Fields(c.Operator), " "))
+
+	values := make([]string, 0, len(c.Values))
+	for _, v := range c.Values {
+		values = append(values, amember.FormatFilterValue(v))
+	}
+
+	//comparisons with NULL are never true in SQL
+	if _, ok := rec[c.Column]; !ok && op != "IS NULL" && op != "IS NOT NULL" && op != "AND" && op != "OR" && op != "NOT" {
+		return false
+	}
+
+	switch op {
+	case "AND", "OR":
+		return matchConditions(rec, op, c.Conditions)
+	case "NOT":
+		return !matchConditions(rec, "AND", c.Conditions)
+	case "IN", "NOT IN":
+		in := false
+		for _, v := range values {
+			in = in || fakeapi.Match(rec, c.Column, "=", v)
+		}
+		return in == (op == "IN")
+	case "BETWEEN":
+		return fakeapi.Match(rec, c.Column, ">=", values[0]) && fakeapi.Match(rec, c.Column, "<=", values[1])
+	case "IS NULL", "IS NOT NULL":
+		_, ok := rec[c.Column]
+		return ok == (op == "IS NOT NULL")
+	case "NOT LIKE":
+		return !fakeapi.Match(rec, c.Column, "LIKE", values[0])
+	}
+
+	return fakeapi.Match(rec, c.Column, op, values[0])
+}
+
+// viewRecord returns the columns of a row of the users view, formatted the way they are compared in SQL
+func viewRecord(u amember.ViewUser) fakeapi.Record {
+
+	rec := fakeapi.Record{}
+
+	elem := reflect.ValueOf(u)
+	for i := 0; i < elem.NumField(); i++ {
+
+		column := elem.Type().Field(i).Tag.Get("json")
+
+		switch v := elem.Field(i).Interface().(type) {
+		case sql.NullTime:
+			if v.Valid {
+				rec[column] = v.Time.Format("2006-01-02 15:04:05")
+			}
+		case amember.Money:
+			rec[column] = v.Decimal()
+		default:
+			rec[column] = fmt.Sprint(v)
+		}
+	}
+
+	return rec
+}
+
+// nullTime returns t as a nullable column, NULL when t is zero
+func nullTime(t time.Time) sql.NullTime {
+
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// sameDay reports whether the datetime column a is on the calendar date of b, the way PaymentsByDate and RefundsByDate query it
+func sameDay(a time.Time, b time.Time) bool {
+
+	return a.Format(dateFormat) == b.Format(dateFormat)
+}
+
+// contains reports whether s contains substr ignoring the case, like the SQL LIKE '%substr%'
+func contains(s amember.String, substr string) bool {
+
+	return strings.Contains(strings.ToLower(string(s)), strings.ToLower(substr))
+}
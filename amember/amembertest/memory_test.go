@@ -0,0 +1,42 @@
+package amembertest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/paperclicks/gomember/amember"
+)
+
+func TestMemoryGetUsersFromViewLimit(t *testing.T) {
+
+	users := make([]amember.User, amember.ViewLimit+1)
+	for i := range users {
+		users[i] = amember.User{Login: amember.String(fmt.Sprintf("u%d", i)), Email: amember.String(fmt.Sprintf("u%d@example.com", i))}
+	}
+
+	m, err := NewMemory(Fixtures{Users: users})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 10, want: 10},
+		{limit: 0, want: amember.ViewLimit},
+		{limit: amember.ViewLimit + 1, want: amember.ViewLimit + 1},
+	}
+
+	for _, tt := range tests {
+
+		got, err := m.GetUsersFromView(nil, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != tt.want {
+			t.Errorf("GetUsersFromView(nil, %d) returned %d users, want %d", tt.limit, len(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package amembertest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Redacted is the prefix of the pseudonyms replacing the values of the redacted fields in the recorded fixtures
+const Redacted = "REDACTED"
+
+// DefaultRedactFields are the fields whose values a Recorder replaces with their Pseudonym: the secrets,
+// and the personal data of the users, so that fixtures recorded from a production install can be committed
+var DefaultRedactFields = []string{
+	"pass", "remember_key", "last_session", "invoice_key", "last_ip", "remote_addr",
+	"email", "login", "name_f", "name_l", "phone", "street", "city", "zip", "taxid",
+}
+
+// Fixture is a GET request to the aMember REST API and its response, as saved by a Recorder
+type Fixture struct {
+	Method string `json:"method"`
+	//URL is the path and query of the request, without the API key
+	URL    string          `json:"url"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// Recorder is an http.RoundTripper saving the GET exchanges with aMember as fixture files in a directory,
+// one file per request, for a Replayer to serve them later:
+//
+//	rec := amembertest.NewRecorder("testdata/amember", nil)
+//	am, err := amember.NewClient(apiURL, apiKey, amember.WithHTTPClient(&http.Client{Transport: rec}))
+//
+// The API key is never saved, and the values of RedactFields are replaced with their Pseudonym.
+type Recorder struct {
+	//Dir is the directory of the fixture files
+	Dir string
+	//Transport sends the requests
+	Transport http.RoundTripper
+	//RedactFields are the fields of the records whose values are replaced with their Pseudonym
+	RedactFields []string
+
+	mu sync.Mutex
+}
+
+// NewRecorder returns a Recorder saving to dir the exchanges made through transport,
+// or through http.DefaultTransport when transport is nil
+func NewRecorder(dir string, transport http.RoundTripper) *Recorder {
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Recorder{Dir: dir, Transport: transport, RedactFields: DefaultRedactFields}
+}
+
+// RoundTrip sends the request, and saves the exchange when it is a GET with a JSON response
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	resp, err := r.Transport.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	//malformed responses are not worth a fixture
+	if !json.Valid(body) {
+		return resp, nil
+	}
+
+	redacted, err := redact(body, r.RedactFields)
+	if err != nil {
+		return nil, fmt.Errorf("amembertest: redact %s: %w", req.URL.Path, err)
+	}
+
+	f := Fixture{Method: req.Method, URL: requestKey(req), Status: resp.StatusCode, Body: redacted}
+
+	err = r.save(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *Recorder) save(f Fixture) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := os.MkdirAll(r.Dir, 0o755)
+	if err != nil {
+		return fmt.Errorf("amembertest: %w", err)
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("amembertest: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(r.Dir, fixtureName(f.Method, f.URL)), b, 0o644)
+	if err != nil {
+		return fmt.Errorf("amembertest: %w", err)
+	}
+
+	return nil
+}
+
+// Replayer is an http.RoundTripper serving the fixtures saved by a Recorder, without network.
+// Requests having no fixture fail.
+type Replayer struct {
+	//Dir is the directory of the fixture files
+	Dir string
+}
+
+// NewReplayer returns a Replayer serving the fixtures in dir
+func NewReplayer(dir string) *Replayer {
+
+	return &Replayer{Dir: dir}
+}
+
+// RoundTrip returns the recorded response to the request
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	key := requestKey(req)
+
+	b, err := os.ReadFile(filepath.Join(r.Dir, fixtureName(req.Method, key)))
+	if err != nil {
+		return nil, fmt.Errorf("amembertest: no fixture for %s %s: %w", req.Method, key, err)
+	}
+
+	f := Fixture{}
+
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("amembertest: fixture for %s %s: %w", req.Method, key, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
+
+// LoadFixtures reads all the fixtures saved in dir
+func LoadFixtures(dir string) ([]Fixture, error) {
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := make([]Fixture, 0, len(files))
+
+	for _, file := range files {
+
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		f := Fixture{}
+
+		err = json.Unmarshal(b, &f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		fixtures = append(fixtures, f)
+	}
+
+	return fixtures, nil
+}
+
+// requestKey returns the path and the query of the request, without the API key. Query parameters are sorted by
+// url.Values.Encode, so that the same request always has the same key.
+func requestKey(req *http.Request) string {
+
+	q := req.URL.Query()
+	q.Del("_key")
+
+	if len(q) == 0 {
+		return req.URL.Path
+	}
+
+	return req.URL.Path + "?" + q.Encode()
+}
+
+// fixtureName returns the file name of the fixture of a request: its path, readable, and a hash of the whole request
+func fixtureName(method string, key string) string {
+
+	path, _, _ := strings.Cut(key, "?")
+
+	name := strings.Trim(strings.NewReplacer("/", "_", ".", "_").Replace(path), "_")
+
+	sum := sha1.Sum([]byte(method + " " + key))
+
+	return fmt.Sprintf("%s_%s_%s.json", strings.ToLower(method), name, hex.EncodeToString(sum[:6]))
+}
+
+// Pseudonym returns the value replacing s in the recorded fixtures: Redacted followed by a short hash of s.
+// Equal values get the same pseudonym and distinct values distinct ones, so the records keyed by a redacted field,
+// like the users keyed by login, stay distinct on replay. A pseudonym can be checked against a guessed value.
+func Pseudonym(s string) string {
+
+	sum := sha1.Sum([]byte(s))
+
+	return Redacted + "-" + hex.EncodeToString(sum[:4])
+}
+
+// redact replaces the string values of fields, at any depth of the JSON document b, with their Pseudonym
+func redact(b []byte, fields []string) (json.RawMessage, error) {
+
+	if len(fields) == 0 {
+		return b, nil
+	}
+
+	secret := make(map[string]bool)
+	for _, f := range fields {
+		secret[f] = true
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+
+	err := d.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(redactValue(v, secret))
+}
+
+func redactValue(v interface{}, secret map[string]bool) interface{} {
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if s, ok := e.(string); ok && secret[k] && s != "" {
+				t[k] = Pseudonym(s)
+				continue
+			}
+			t[k] = redactValue(e, secret)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redactValue(e, secret)
+		}
+	}
+
+	return v
+}
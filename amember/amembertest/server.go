@@ -0,0 +1,219 @@
+// Package amembertest provides a fake aMember REST server, for testing code that uses the amember package
+// without a live aMember install.
+//
+//	srv := amembertest.NewServer(amembertest.WithFixtures(Fixtures{
+//		Users: []amember.User{{Login: "jdoe", Email: "jdoe@example.com", Status: amember.UserActive}},
+//	}))
+//	defer srv.Close()
+//
+//	am, err := srv.Client()
+//	users, err := am.UsersContext(ctx, amember.Params{})
+package amembertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paperclicks/gomember/amember"
+)
+
+// DefaultKey is the API key accepted by a Server created without WithKey
+const DefaultKey = "amembertest"
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithKey makes the server accept only requests carrying key
+func WithKey(key string) Option {
+
+	return func(s *Server) {
+		s.Key = key
+	}
+}
+
+// WithFixtures seeds the server with the given records
+func WithFixtures(f Fixtures) Option {
+
+	return func(s *Server) {
+		s.fixtures = f
+	}
+}
+
+// WithLatency delays every response by d
+func WithLatency(d time.Duration) Option {
+
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// Fault is an error injected in the responses of a Server
+type Fault struct {
+	//Path restricts the fault to the requests whose path starts with it, e.g. "/api/users"; empty matches every request
+	Path string
+	//Status is the HTTP status of the response, e.g. http.StatusBadGateway; 0 keeps 200
+	Status int
+	//Malformed makes the response body truncated JSON
+	Malformed bool
+	//Latency delays the response, on top of the server latency
+	Latency time.Duration
+	//Times is the number of requests the fault applies to; 0 means every request until Reset
+	Times int
+}
+
+// Server is an httptest.Server implementing the aMember REST API endpoints used by the amember package:
+// /api/users, /api/invoices, /api/access, /api/invoice-payments, /api/products and /api/product-product-category.
+// Responses have the shapes of aMember: collections keyed by the position in the page together with _total,
+// nested blocks requested with _nested[], records filtered with _filter and paginated with _page and _count.
+type Server struct {
+	*httptest.Server
+
+	//Key is the API key the server accepts, as the X-API-Key header or the _key query parameter
+	Key string
+
+	//Memory holds the records of the server: what is written through the REST API is visible to it, and vice versa
+	Memory *Memory
+
+	fixtures Fixtures
+
+	mu       sync.Mutex
+	latency  time.Duration
+	faults   []*Fault
+	requests int
+}
+
+// NewServer starts a Server configured by opts. It panics if the fixtures cannot be stored.
+// The caller should call Close when finished, to shut it down.
+func NewServer(opts ...Option) *Server {
+
+	s := &Server{Key: DefaultKey}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	m, err := NewMemory(s.fixtures)
+	if err != nil {
+		panic("amembertest: " + err.Error())
+	}
+
+	s.Memory = m
+	s.Server = httptest.NewServer(s.handler(m.Handler(s.Key)))
+
+	return s
+}
+
+// Client returns an *amember.Amember talking to the server with its key, configured by opts
+func (s *Server) Client(opts ...amember.Option) (*amember.Amember, error) {
+
+	return amember.NewClient(s.URL, s.Key, append([]amember.Option{amember.WithHTTPClient(s.Server.Client())}, opts...)...)
+}
+
+// SetLatency delays every following response by d
+func (s *Server) SetLatency(d time.Duration) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+}
+
+// Inject adds a fault to the following responses. Faults apply in the order they were injected,
+// the first one matching a request is used.
+func (s *Server) Inject(f Fault) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// Reset removes the injected faults and the latency
+func (s *Server) Reset() {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+	s.latency = 0
+}
+
+// Requests returns the number of requests received so far, including the ones answered with a fault
+func (s *Server) Requests() int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// handler wraps the REST API with latency and fault injection
+func (s *Server) handler(api http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		latency, fault := s.next(r)
+
+		if fault != nil {
+			latency += fault.Latency
+		}
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if fault == nil || (fault.Status == 0 && !fault.Malformed) {
+			api.ServeHTTP(w, r)
+			return
+		}
+
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+
+		if fault.Malformed {
+			w.Write([]byte(`{"0":{"user_id":"1","login":`))
+			return
+		}
+
+		w.Write([]byte(`{"error":true,"message":"` + http.StatusText(status) + `"}`))
+	})
+}
+
+// next counts the request, and returns the latency and the fault to apply to it
+func (s *Server) next(r *http.Request) (time.Duration, *Fault) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	for i, f := range s.faults {
+
+		if !strings.HasPrefix(r.URL.Path, f.Path) {
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+
+		return s.latency, f
+	}
+
+	return s.latency, nil
+}
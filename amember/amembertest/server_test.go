@@ -0,0 +1,217 @@
+package amembertest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/paperclicks/gomember/amember"
+)
+
+// newServer returns a server holding 3 users, and a client of it
+func newServer(t *testing.T, opts ...Option) (*Server, *amember.Amember) {
+
+	t.Helper()
+
+	opts = append([]Option{WithFixtures(Fixtures{Users: []amember.User{{Login: "a"}, {Login: "b"}, {Login: "c"}}})}, opts...)
+
+	srv := NewServer(opts...)
+	t.Cleanup(srv.Close)
+
+	am, err := srv.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, am
+}
+
+// status returns the HTTP status of the APIError err, 0 if err is nil, and -1 if err is not an APIError
+func status(err error) int {
+
+	if err == nil {
+		return 0
+	}
+
+	var apiErr *amember.APIError
+	if !errors.As(err, &apiErr) {
+		return -1
+	}
+
+	return apiErr.StatusCode
+}
+
+func TestServerFaults(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		faults []Fault
+		//want is the status of the successive requests to /api/users, -1 for a response that is not an APIError
+		want []int
+	}{
+		{name: "no fault", want: []int{0, 0}},
+		{name: "status", faults: []Fault{{Status: http.StatusBadGateway}}, want: []int{502, 502, 502}},
+		{name: "times", faults: []Fault{{Status: http.StatusServiceUnavailable, Times: 2}}, want: []int{503, 503, 0}},
+		{name: "path", faults: []Fault{{Path: "/api/access", Status: http.StatusBadGateway}}, want: []int{0}},
+		{name: "path prefix", faults: []Fault{{Path: "/api/user", Status: http.StatusBadGateway, Times: 1}}, want: []int{502, 0}},
+		{name: "malformed", faults: []Fault{{Malformed: true, Times: 1}}, want: []int{-1, 0}},
+		{name: "error payload", faults: []Fault{{Status: http.StatusOK, Times: 1}}, want: []int{200, 0}},
+		{name: "in order", faults: []Fault{{Status: http.StatusBadGateway, Times: 1}, {Status: http.StatusTooManyRequests, Times: 1}}, want: []int{502, 429, 0}},
+		//a fault with neither a status nor a malformed body lets the request through, while counting down
+		{name: "pass through", faults: []Fault{{Times: 1}, {Status: http.StatusBadGateway, Times: 1}}, want: []int{0, 502, 0}},
+	}
+
+	for _, tt := range tests {
+
+		srv, am := newServer(t)
+
+		for _, f := range tt.faults {
+			srv.Inject(f)
+		}
+
+		for i, want := range tt.want {
+
+			n, err := am.Count(ctx, "users", amember.Params{})
+
+			if got := status(err); got != want || (err == nil && n != 3) {
+				t.Errorf("%s: request %d: %d users, %v, want status %d", tt.name, i+1, n, err, want)
+			}
+		}
+
+		if srv.Requests() != len(tt.want) {
+			t.Errorf("%s: %d requests, want %d", tt.name, srv.Requests(), len(tt.want))
+		}
+	}
+}
+
+func TestServerReset(t *testing.T) {
+
+	srv, am := newServer(t, WithLatency(time.Hour))
+
+	srv.Inject(Fault{Status: http.StatusBadGateway})
+	srv.Reset()
+
+	n, err := am.Count(context.Background(), "users", amember.Params{})
+	if err != nil || n != 3 {
+		t.Errorf("Count() after Reset = %d, %v, want 3 users", n, err)
+	}
+}
+
+func TestServerLatency(t *testing.T) {
+
+	const latency = 50 * time.Millisecond
+
+	srv, am := newServer(t, WithLatency(latency))
+
+	ctx := context.Background()
+
+	elapsed := func() time.Duration {
+
+		start := time.Now()
+
+		_, err := am.Count(ctx, "users", amember.Params{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return time.Since(start)
+	}
+
+	if d := elapsed(); d < latency {
+		t.Errorf("WithLatency(%s): request took %s", latency, d)
+	}
+
+	srv.Inject(Fault{Latency: latency, Times: 1})
+	if d := elapsed(); d < 2*latency {
+		t.Errorf("fault latency on top of the server latency: request took %s, want at least %s", d, 2*latency)
+	}
+
+	srv.SetLatency(0)
+	srv.Inject(Fault{Latency: time.Hour, Times: 1})
+
+	//a client giving up stops the wait
+	ctx, cancel := context.WithTimeout(ctx, latency)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := am.Count(ctx, "users", amember.Params{}); err == nil {
+		t.Error("Count() with a context shorter than the latency: want an error")
+	}
+
+	if d := time.Since(start); d > time.Minute {
+		t.Errorf("Count() with a context shorter than the latency took %s", d)
+	}
+}
+
+func TestServerKey(t *testing.T) {
+
+	srv, _ := newServer(t, WithKey("secret"))
+
+	ctx := context.Background()
+
+	tests := []struct {
+		key  string
+		opts []amember.Option
+		ok   bool
+	}{
+		{key: "secret", ok: true},
+		{key: "secret", opts: []amember.Option{amember.WithAPIKeyInQuery()}, ok: true},
+		{key: "wrong"},
+		{key: "wrong", opts: []amember.Option{amember.WithAPIKeyInQuery()}},
+	}
+
+	for _, tt := range tests {
+
+		am, err := amember.NewClient(srv.URL, tt.key, append([]amember.Option{amember.WithHTTPClient(srv.Server.Client())}, tt.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = am.Count(ctx, "users", amember.Params{})
+
+		if tt.ok && err != nil {
+			t.Errorf("key %q, %d options: %v", tt.key, len(tt.opts), err)
+		}
+
+		if !tt.ok && !amember.IsUnauthorized(err) {
+			t.Errorf("key %q, %d options: %v, want an unauthorized error", tt.key, len(tt.opts), err)
+		}
+	}
+}
+
+func TestServerMemory(t *testing.T) {
+
+	srv, am := newServer(t)
+
+	ctx := context.Background()
+
+	u, err := am.CreateUser(ctx, amember.User{Login: "d", Email: "d@example.com", Status: amember.UserActive})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//what is written through the REST API is visible to Memory
+	users, err := srv.Memory.UsersContext(ctx, amember.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := users["d"]; !ok || got.UserID != u.UserID || got.Email != "d@example.com" {
+		t.Errorf("Memory.UsersContext() = %+v, want the user created through the server", users)
+	}
+
+	//and vice versa
+	_, err = srv.Memory.CreateUser(ctx, amember.User{Login: "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := am.Count(ctx, "users", amember.Params{})
+	if err != nil || n != 5 {
+		t.Errorf("Count() = %d, %v, want 5 users", n, err)
+	}
+}
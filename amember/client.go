@@ -0,0 +1,92 @@
+package amember
+
+import (
+	"context"
+	"time"
+)
+
+// UserReader reads users from the REST API
+type UserReader interface {
+	UsersContext(ctx context.Context, p Params) (map[string]User, error)
+	MembershipsContext(ctx context.Context, p Params, activeAccessOnly bool) (map[string]Membership, error)
+	IterUsers(ctx context.Context, p Params) *Iterator[User]
+}
+
+// UserWriter creates, updates and deletes users through the REST API
+type UserWriter interface {
+	CreateUser(ctx context.Context, u User) (User, error)
+	UpdateUser(ctx context.Context, id int, fields map[string]string) (User, error)
+	DeleteUser(ctx context.Context, id int) error
+}
+
+// InvoiceReader reads invoices from the REST API
+type InvoiceReader interface {
+	InvoicesContext(ctx context.Context, p Params) (map[int]Invoice, error)
+	InvoiceContext(ctx context.Context, id int, nested ...string) (Invoice, error)
+	IterInvoices(ctx context.Context, p Params) *Iterator[Invoice]
+}
+
+// InvoiceWriter creates invoices through the REST API
+type InvoiceWriter interface {
+	NewInvoice(invoice Invoice) *InvoiceBuilder
+}
+
+// AccessReader reads access records from the REST API
+type AccessReader interface {
+	AccessesContext(ctx context.Context, p Params, activeOnly bool) (map[int][]Access, error)
+	IterAccesses(ctx context.Context, p Params) *Iterator[Access]
+}
+
+// AccessWriter grants, extends and revokes access records through the REST API
+type AccessWriter interface {
+	GrantAccess(ctx context.Context, userID int, productID int, begin time.Time, expire time.Time, comment string, allowOverlap bool) (Access, error)
+	ExtendAccess(ctx context.Context, accessID int, newExpire time.Time) (Access, error)
+	RevokeAccess(ctx context.Context, accessID int) (Access, error)
+}
+
+// PaymentReader reads invoice payments from the REST API
+type PaymentReader interface {
+	PaymentsContext(ctx context.Context, p Params) (map[int]Payment, error)
+	IterPayments(ctx context.Context, p Params) *Iterator[Payment]
+}
+
+// ProductReader reads products and product categories from the REST API
+type ProductReader interface {
+	ProductsContext(ctx context.Context, p Params) (map[int]Product, error)
+	ProductCategoriesContext(ctx context.Context) (map[int]map[int]int, error)
+	IterProducts(ctx context.Context, p Params) *Iterator[Product]
+}
+
+// Counter counts the records of a REST collection
+type Counter interface {
+	Count(ctx context.Context, resource string, p Params) (int, error)
+}
+
+// DBReader reads from the aMember database and from the users view
+type DBReader interface {
+	MembershipsFromDB() (map[string]Membership, error)
+	UsersFromDB(status UserStatus, addedFrom time.Time, addedTo time.Time) (map[int]DBUser, error)
+	AccessesFromDB(expiredFrom time.Time, expiredTo time.Time, filters ...AccessesFilter) (map[int][]DBAccess, error)
+	PaymentsByDate(datetime time.Time, itemTitle string, itemDescription string) (map[string]Payment, error)
+	RefundsByDate(datetime time.Time, itemTitle string) (map[string]Payment, error)
+	GetUserFromView(email string) (ViewUser, error)
+	GetUsersFromView(conditions []Condition, limit int) ([]ViewUser, error)
+	QueryUsersView(conditions []Condition, opts QueryOptions) ([]ViewUser, error)
+}
+
+// Client is everything an *Amember does. Code that only needs part of it should depend on the smaller
+// interfaces (UserReader, AccessWriter...), so that it can be tested against an amembertest.Memory client.
+type Client interface {
+	UserReader
+	UserWriter
+	InvoiceReader
+	InvoiceWriter
+	AccessReader
+	AccessWriter
+	PaymentReader
+	ProductReader
+	Counter
+	DBReader
+}
+
+var _ Client = (*Amember)(nil)
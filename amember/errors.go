@@ -0,0 +1,80 @@
+package amember
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is the error returned when aMember answers a REST request with an error payload or a non 2xx status.
+// Use errors.As to inspect it, or the IsNotFound, IsUnauthorized and IsRateLimited helpers.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Endpoint   string
+	Message    string
+	Body       []byte
+
+	//retryAfter is the wait requested by aMember with a Retry-After header
+	retryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+
+	return fmt.Sprintf("amember: %s %s: [%d] %s", e.Method, e.Endpoint, e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is an APIError for a record or endpoint that does not exist
+func IsNotFound(err error) bool {
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode == http.StatusNotFound {
+		return true
+	}
+
+	msg := strings.ToLower(apiErr.Message)
+
+	return strings.Contains(msg, "not found") && !isKeyMessage(msg)
+}
+
+// IsUnauthorized reports whether err is an APIError caused by a missing, wrong or disabled API key
+func IsUnauthorized(err error) bool {
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden {
+		return true
+	}
+
+	return isKeyMessage(strings.ToLower(apiErr.Message))
+}
+
+// IsRateLimited reports whether err is an APIError caused by too many requests
+func IsRateLimited(err error) bool {
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// isKeyMessage reports whether an aMember error message is about the API key.
+// aMember answers with e.g. "API Error 10002 - [key] is not found or disabled" and a 200 status.
+func isKeyMessage(msg string) bool {
+
+	return strings.Contains(msg, "[key]") || strings.Contains(msg, "api key")
+}
+
+// ErrOverlappingAccess is returned by GrantAccess when the user already has an access to the product for the requested period
+var ErrOverlappingAccess = errors.New("overlapping access")
@@ -0,0 +1,65 @@
+package amember
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorPayloads(t *testing.T) {
+
+	tests := []struct {
+		body         string
+		status       int
+		wantErr      bool
+		message      string
+		unauthorized bool
+	}{
+		{body: `{"0":{"user_id":"1","login":"jdoe"},"_total":1}`},
+		{body: `{"error":false,"0":{"user_id":"1","login":"jdoe"},"_total":1}`},
+		{body: `{"error":0,"_total":0}`},
+		{body: `{"error":"","_total":0}`},
+		{body: `{"error":null,"_total":0}`},
+		{body: `[]`},
+		{body: `{"error":true,"message":"API Error 10002 - [key] is not found or disabled"}`, wantErr: true, message: "API Error 10002 - [key] is not found or disabled", unauthorized: true},
+		{body: `{"error":"Invalid API key"}`, wantErr: true, message: "Invalid API key", unauthorized: true},
+		{body: `{"error":1,"message":"Record not found"}`, wantErr: true, message: "Record not found"},
+		{body: `{"error":"1"}`, wantErr: true, message: "1"},
+		{body: `{"error":{"code":10002},"message":"[key] is disabled"}`, wantErr: true, message: "[key] is disabled", unauthorized: true},
+		{body: `{"error":true,"message":"Server Error"}`, status: http.StatusBadRequest, wantErr: true, message: "Server Error"},
+		{body: `<html>Bad Gateway</html>`, status: http.StatusBadRequest, wantErr: true, message: "Bad Request"},
+	}
+
+	for _, tt := range tests {
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.status != 0 {
+				w.WriteHeader(tt.status)
+			}
+			w.Write([]byte(tt.body))
+		}))
+
+		am, err := NewClient(srv.URL, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = am.doRequest(context.Background(), http.MethodGet, srv.URL+"/api/users", nil)
+		srv.Close()
+
+		var apiErr *APIError
+
+		switch {
+		case !tt.wantErr && err != nil:
+			t.Errorf("%s: %v, want no error", tt.body, err)
+		case tt.wantErr && !errors.As(err, &apiErr):
+			t.Errorf("%s: %v, want an *APIError", tt.body, err)
+		case tt.wantErr && apiErr.Message != tt.message:
+			t.Errorf("%s: message %q, want %q", tt.body, apiErr.Message, tt.message)
+		case tt.wantErr && IsUnauthorized(err) != tt.unauthorized:
+			t.Errorf("%s: IsUnauthorized = %v, want %v", tt.body, IsUnauthorized(err), tt.unauthorized)
+		}
+	}
+}
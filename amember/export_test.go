@@ -0,0 +1,12 @@
+package amember
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Crawl exposes crawl to the tests of the amember_test package, which can use amembertest
+func (am *Amember) Crawl(ctx context.Context, endpoint string, p Params, fn func(k string, v json.RawMessage) error) error {
+
+	return am.crawl(ctx, endpoint, p, fn)
+}
@@ -0,0 +1,177 @@
+package amember
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter builds the _filter parameters of a REST request.
+//
+//	f := NewFilter().Eq("status", 1).Between("added", from, to).Like("email", "%@example.com")
+//	users, err := am.UsersContext(ctx, Params{Where: f})
+//
+// Field names are checked against the json tags of the model of the requested collection.
+type Filter struct {
+	conditions []filterCondition
+}
+
+type filterCondition struct {
+	field string
+	op    string
+	value string
+}
+
+// NewFilter returns an empty Filter
+func NewFilter() *Filter {
+
+	return &Filter{}
+}
+
+// Eq matches the records having field equal to value
+func (f *Filter) Eq(field string, value interface{}) *Filter {
+
+	return f.add(field, "", value)
+}
+
+// NotEq matches the records having field different from value
+func (f *Filter) NotEq(field string, value interface{}) *Filter {
+
+	return f.add(field, "<>", value)
+}
+
+// Like matches the records having field like pattern, where % is the usual SQL wildcard
+func (f *Filter) Like(field string, pattern string) *Filter {
+
+	return f.add(field, "LIKE", pattern)
+}
+
+// Gt matches the records having field greater than value
+func (f *Filter) Gt(field string, value interface{}) *Filter {
+
+	return f.add(field, ">", value)
+}
+
+// Gte matches the records having field greater than or equal to value
+func (f *Filter) Gte(field string, value interface{}) *Filter {
+
+	return f.add(field, ">=", value)
+}
+
+// Lt matches the records having field less than value
+func (f *Filter) Lt(field string, value interface{}) *Filter {
+
+	return f.add(field, "<", value)
+}
+
+// Lte matches the records having field less than or equal to value
+func (f *Filter) Lte(field string, value interface{}) *Filter {
+
+	return f.add(field, "<=", value)
+}
+
+// Between matches the records having field between from and to, both included. It is typically used on date fields.
+func (f *Filter) Between(field string, from interface{}, to interface{}) *Filter {
+
+	return f.Gte(field, from).Lte(field, to)
+}
+
+func (f *Filter) add(field string, op string, value interface{}) *Filter {
+
+	f.conditions = append(f.conditions, filterCondition{field: field, op: op, value: FormatFilterValue(value)})
+
+	return f
+}
+
+// encode adds the filter conditions to v. When model is not nil, every field must match one of its json tags.
+func (f *Filter) encode(v url.Values, model reflect.Type) error {
+
+	if f == nil {
+		return nil
+	}
+
+	var fields map[string]bool
+	if model != nil {
+		fields = jsonFields(model)
+	}
+
+	for _, c := range f.conditions {
+
+		if fields != nil && !fields[c.field] {
+			return fmt.Errorf("unknown filter field [%s] for %s", c.field, model.Name())
+		}
+
+		if c.op == "" {
+			v.Add(fmt.Sprintf("_filter[%s]", c.field), c.value)
+			continue
+		}
+
+		v.Add(fmt.Sprintf("_filter[%s][%s]", c.field, c.op), c.value)
+	}
+
+	return nil
+}
+
+// FormatFilterValue converts a filter value to the format aMember expects: dates as dates, datetimes as datetimes,
+// booleans as 1 and 0, and the status enums as their code. Filter formats its values with it.
+func FormatFilterValue(value interface{}) string {
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		//date columns are compared as dates, datetime ones as datetimes
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case CustomTime:
+		return FormatFilterValue(v.Time)
+	case Bool:
+		return FormatFilterValue(bool(v))
+	case Money:
+		return v.Decimal()
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+
+	//status enums are sent as their code, not as their name
+	if rv := reflect.ValueOf(value); rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64 {
+		return strconv.FormatInt(rv.Int(), 10)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// endpointModels maps the REST collections to the model their records are decoded into
+var endpointModels = map[string]reflect.Type{
+	"users":            reflect.TypeOf(User{}),
+	"invoices":         reflect.TypeOf(Invoice{}),
+	"access":           reflect.TypeOf(Access{}),
+	"invoice-payments": reflect.TypeOf(Payment{}),
+	"products":         reflect.TypeOf(Product{}),
+}
+
+// jsonFields returns the set of json tags of the fields of a struct type
+func jsonFields(t reflect.Type) map[string]bool {
+
+	fields := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		fields[tag] = true
+	}
+
+	return fields
+}
@@ -0,0 +1,81 @@
+// Package gologadapter adapts a golog instance to the amember.Logger interface, for the applications that
+// keep logging through golog:
+//
+//	am, err := amember.NewClient(apiURL, apiKey, amember.WithLogger(gologadapter.New(gl)))
+//
+// It also holds the constructors taking a *golog.Golog that used to be amember.New and amember.NewWithDb,
+// so that the amember package does not depend on golog.
+package gologadapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paperclicks/golog"
+	"github.com/paperclicks/gomember/amember"
+)
+
+// Logger logs through golog. The attributes are appended to the message as key=value pairs.
+// A nil Logger, or one wrapping a nil golog, discards everything.
+type Logger struct {
+	gl *golog.Golog
+}
+
+// New returns a Logger writing to gl
+func New(gl *golog.Golog) *Logger {
+
+	return &Logger{gl: gl}
+}
+
+func (l *Logger) Debug(msg string, args ...any) { l.log(golog.DEBUG, msg, args) }
+func (l *Logger) Info(msg string, args ...any)  { l.log(golog.INFO, msg, args) }
+func (l *Logger) Warn(msg string, args ...any)  { l.log(golog.WARNING, msg, args) }
+func (l *Logger) Error(msg string, args ...any) { l.log(golog.ERROR, msg, args) }
+
+func (l *Logger) log(level int, msg string, args []any) {
+
+	if l == nil || l.gl == nil {
+		return
+	}
+
+	l.gl.Log(formatAttrs(msg, args), level)
+}
+
+// formatAttrs renders a message and its attributes as "msg key1=value1 key2=value2"
+func formatAttrs(msg string, args []any) string {
+
+	var sb strings.Builder
+	sb.WriteString(msg)
+
+	for i := 0; i < len(args); i += 2 {
+
+		if i+1 == len(args) {
+			fmt.Fprintf(&sb, " %v", args[i])
+			break
+		}
+
+		fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+	}
+
+	return sb.String()
+}
+
+// NewAmember returns a client for the aMember REST API logging through gl. It replaces amember.New.
+//
+// Deprecated: use amember.NewClient with amember.WithLogger(New(gl)).
+func NewAmember(apiURL string, apiKey string, gl *golog.Golog, opts ...amember.Option) *amember.Amember {
+
+	//NewClient can only fail when opening a database
+	am, _ := amember.NewClient(apiURL, apiKey, append([]amember.Option{amember.WithLogger(New(gl))}, opts...)...)
+
+	return am
+}
+
+// NewAmemberWithDb returns a client for the aMember REST API and for the aMember database at dburi, logging through gl.
+// It replaces amember.NewWithDb.
+//
+// Deprecated: use amember.NewClient with amember.WithLogger(New(gl)) and amember.WithDSN or amember.WithDB.
+func NewAmemberWithDb(apiURL string, apiKey string, dburi string, gl *golog.Golog, opts ...amember.Option) (*amember.Amember, error) {
+
+	return amember.NewClient(apiURL, apiKey, append([]amember.Option{amember.WithLogger(New(gl)), amember.WithDSN(dburi)}, opts...)...)
+}
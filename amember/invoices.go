@@ -0,0 +1,144 @@
+package amember
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// InvoiceBuilder collects an invoice together with its nested invoice items, payments and access records,
+// so that they can be inserted with a single request to /api/invoices.
+//
+//	price := Money{Amount: 4900, Currency: "EUR"}
+//	inv, err := am.NewInvoice(Invoice{UserID: 12, PaysysID: "manual", Currency: "EUR"}).
+//		AddItem(InvoiceItem{ItemID: 3, ItemType: "product", Qty: 1, FirstPrice: price, FirstTotal: price, FirstPeriod: Period{Count: 1, Unit: PeriodMonth}}).
+//		AddPayment(Payment{Amount: price, Currency: "EUR", Dattm: CustomTime{time.Now()}}).
+//		AddAccess(Access{ProductID: 3, BeginDate: CustomTime{begin}, ExpireDate: CustomTime{expire}}).
+//		Create(ctx)
+type InvoiceBuilder struct {
+	am       *Amember
+	invoice  Invoice
+	items    []InvoiceItem
+	payments []Payment
+	accesses []Access
+}
+
+// NewInvoice returns an InvoiceBuilder for the given invoice. The invoice must have at least UserID set;
+// InvoiceID and the nested block are ignored.
+func (am *Amember) NewInvoice(invoice Invoice) *InvoiceBuilder {
+
+	return &InvoiceBuilder{am: am, invoice: invoice}
+}
+
+// AddItem adds an invoice item to the invoice
+func (b *InvoiceBuilder) AddItem(item InvoiceItem) *InvoiceBuilder {
+
+	b.items = append(b.items, item)
+
+	return b
+}
+
+// AddPayment adds a payment to the invoice, e.g. to record a sale paid off-platform
+func (b *InvoiceBuilder) AddPayment(payment Payment) *InvoiceBuilder {
+
+	b.payments = append(b.payments, payment)
+
+	return b
+}
+
+// AddAccess adds an access record to the invoice, granting the user access to a product
+func (b *InvoiceBuilder) AddAccess(access Access) *InvoiceBuilder {
+
+	b.accesses = append(b.accesses, access)
+
+	return b
+}
+
+// Create inserts the invoice and its nested records, and returns the invoice as stored by aMember,
+// including the nested records with their assigned IDs. If the invoice is created but cannot be read back, it is returned
+// as decoded from the insert response, with its InvoiceID, together with the error: do not create it again.
+func (b *InvoiceBuilder) Create(ctx context.Context) (Invoice, error) {
+
+	if b.invoice.UserID == 0 {
+		return Invoice{}, errors.New("create invoice: missing user_id")
+	}
+
+	if len(b.items) == 0 {
+		return Invoice{}, errors.New("create invoice: at least one invoice item is required")
+	}
+
+	form := structToForm(b.invoice)
+
+	//IDs are assigned by aMember
+	form.Del("invoice_id")
+
+	for i, item := range b.items {
+		encodeForm(form, fmt.Sprintf("nested[invoice-items][%d]", i), item)
+		form.Del(fmt.Sprintf("nested[invoice-items][%d][invoice_id]", i))
+		form.Del(fmt.Sprintf("nested[invoice-items][%d][invoice_item_id]", i))
+	}
+
+	for i, payment := range b.payments {
+		encodeForm(form, fmt.Sprintf("nested[invoice-payments][%d]", i), payment)
+		form.Del(fmt.Sprintf("nested[invoice-payments][%d][invoice_id]", i))
+		form.Del(fmt.Sprintf("nested[invoice-payments][%d][invoice_payment_id]", i))
+		form.Set(fmt.Sprintf("nested[invoice-payments][%d][user_id]", i), strconv.Itoa(int(b.invoice.UserID)))
+	}
+
+	for i, access := range b.accesses {
+		encodeForm(form, fmt.Sprintf("nested[access][%d]", i), access)
+		form.Del(fmt.Sprintf("nested[access][%d][invoice_id]", i))
+		form.Del(fmt.Sprintf("nested[access][%d][access_id]", i))
+		form.Set(fmt.Sprintf("nested[access][%d][user_id]", i), strconv.Itoa(int(b.invoice.UserID)))
+	}
+
+	response, err := b.am.doRequest(ctx, http.MethodPost, b.am.APIURL+"/api/invoices", form)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("create invoice: %w", err)
+	}
+
+	m, err := firstRecord(response)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("create invoice: %w", err)
+	}
+
+	invoice, err := decodeRecord[Invoice]("0", m)
+	if err != nil {
+		return invoice, fmt.Errorf("create invoice: %w", err)
+	}
+
+	//read the invoice back, so that the nested records come with the IDs assigned by aMember.
+	//The invoice is already stored at this point: on failure return it, with its ID, so that it is not created again.
+	stored, err := b.am.InvoiceContext(ctx, int(invoice.InvoiceID), "invoice-items", "invoice-payments", "access")
+	if err != nil {
+		return invoice, fmt.Errorf("create invoice: invoice %d created, reading it back failed: %w", invoice.InvoiceID, err)
+	}
+
+	return stored, nil
+}
+
+// InvoiceContext returns the invoice having the given invoice_id, including the nested records requested with nested
+func (am *Amember) InvoiceContext(ctx context.Context, id int, nested ...string) (Invoice, error) {
+
+	params := url.Values{}
+	for _, v := range nested {
+		params.Add("_nested[]", v)
+	}
+
+	url := fmt.Sprintf("%s/api/invoices/%d?%s", am.APIURL, id, params.Encode())
+
+	response, err := am.doRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("invoice %d: %w", id, err)
+	}
+
+	m, err := firstRecord(response)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("invoice %d: %w", id, err)
+	}
+
+	return decodeRecord[Invoice](strconv.Itoa(id), m)
+}
@@ -0,0 +1,125 @@
+package amember
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Iterator walks a REST collection one page at a time, so that only the current page is kept in memory.
+//
+//	it := am.IterUsers(ctx, Params{Count: 500})
+//	for it.Next() {
+//		u := it.Value()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	am       *Amember
+	ctx      context.Context
+	endpoint string
+	params   Params
+	decode   func(k string, v json.RawMessage) (T, error)
+
+	page    []record
+	current T
+	last    bool
+	total   int
+	err     error
+}
+
+func newIterator[T any](ctx context.Context, am *Amember, endpoint string, p Params, decode func(k string, v json.RawMessage) (T, error)) *Iterator[T] {
+
+	return &Iterator[T]{am: am, ctx: ctx, endpoint: endpoint, params: p, decode: decode, total: -1}
+}
+
+// Next advances the iterator to the next record, requesting the next page when the current one is exhausted.
+// It returns false when the collection is over or an error occurred; check Err to tell the two apart.
+func (it *Iterator[T]) Next() bool {
+
+	if it.err != nil {
+		return false
+	}
+
+	//request pages until one with records is found, or the collection is over
+	for len(it.page) == 0 {
+
+		if it.last {
+			return false
+		}
+
+		page, err := it.am.fetchPage(it.ctx, it.endpoint, it.params)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = page.records
+		it.last = page.last
+		it.total = page.total
+		it.params.Page++
+	}
+
+	r := it.page[0]
+	it.page = it.page[1:]
+
+	v, err := it.decode(r.key, r.value)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.current = v
+
+	return true
+}
+
+// Value returns the record the iterator is currently positioned on
+func (it *Iterator[T]) Value() T {
+
+	return it.current
+}
+
+// Total returns the number of records of the whole collection as reported by aMember with _total.
+// It is -1 before the first call to Next, or if aMember did not report it.
+func (it *Iterator[T]) Total() int {
+
+	return it.total
+}
+
+// Err returns the first error encountered while iterating, if any
+func (it *Iterator[T]) Err() error {
+
+	return it.err
+}
+
+// IterUsers returns an Iterator over the records of /api/users
+func (am *Amember) IterUsers(ctx context.Context, p Params) *Iterator[User] {
+
+	return newIterator(ctx, am, "users", p, decodeRecord[User])
+}
+
+// IterInvoices returns an Iterator over the records of /api/invoices, including any nested record requested with p.Nested
+func (am *Amember) IterInvoices(ctx context.Context, p Params) *Iterator[Invoice] {
+
+	return newIterator(ctx, am, "invoices", p, decodeRecord[Invoice])
+}
+
+// IterAccesses returns an Iterator over the records of /api/access
+func (am *Amember) IterAccesses(ctx context.Context, p Params) *Iterator[Access] {
+
+	return newIterator(ctx, am, "access", p, decodeRecord[Access])
+}
+
+// IterPayments returns an Iterator over the records of /api/invoice-payments
+func (am *Amember) IterPayments(ctx context.Context, p Params) *Iterator[Payment] {
+
+	return newIterator(ctx, am, "invoice-payments", p, decodeRecord[Payment])
+}
+
+// IterProducts returns an Iterator over the records of /api/products
+func (am *Amember) IterProducts(ctx context.Context, p Params) *Iterator[Product] {
+
+	return newIterator(ctx, am, "products", p, decodeRecord[Product])
+}
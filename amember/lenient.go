@@ -0,0 +1,139 @@
+package amember
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// aMember is not consistent in the JSON types it uses: the same field can come as 1, "1", true, "" or null
+// depending on the version, the endpoint, or whether the record was just created. The types below decode
+// any of these representations, and are used by the REST models in place of the plain Go types.
+
+// Int is an int decoded from a JSON number, a numeric string, a bool, "" or null
+type Int int
+
+// Float is a float64 decoded from a JSON number, a numeric string, "" or null
+type Float float64
+
+// String is a string decoded from a JSON string, number, bool or null
+type String string
+
+// Bool is a bool decoded from a JSON bool, a number, "0"/"1", "true"/"false", "" or null
+type Bool bool
+
+func (i *Int) UnmarshalJSON(b []byte) error {
+
+	s, err := scalar(b)
+	if err != nil {
+		return fmt.Errorf("int: %w", err)
+	}
+
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		*i = 0
+		return nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		*i = Int(n)
+		return nil
+	}
+
+	//integers are sometimes sent as decimals, e.g. "1.00"
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("int: invalid value %s", b)
+	}
+
+	*i = Int(f)
+
+	return nil
+}
+
+func (f *Float) UnmarshalJSON(b []byte) error {
+
+	s, err := scalar(b)
+	if err != nil {
+		return fmt.Errorf("float: %w", err)
+	}
+
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		*f = 0
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("float: invalid value %s", b)
+	}
+
+	*f = Float(v)
+
+	return nil
+}
+
+func (s *String) UnmarshalJSON(b []byte) error {
+
+	v, err := scalar(b)
+	if err != nil {
+		return fmt.Errorf("string: %w", err)
+	}
+
+	*s = String(v)
+
+	return nil
+}
+
+func (v *Bool) UnmarshalJSON(b []byte) error {
+
+	s, err := scalar(b)
+	if err != nil {
+		return fmt.Errorf("bool: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "0", "false":
+		*v = false
+	case "1", "true":
+		*v = true
+	default:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("bool: invalid value %s", b)
+		}
+		*v = f != 0
+	}
+
+	return nil
+}
+
+// scalar returns the text of a JSON string, number or bool, and "" for null.
+// Bools are returned as "1" and "0".
+func scalar(b []byte) (string, error) {
+
+	b = bytes.TrimSpace(b)
+
+	switch {
+	case len(b) == 0 || string(b) == "null":
+		return "", nil
+	case b[0] == '"':
+		var s string
+		err := json.Unmarshal(b, &s)
+		return s, err
+	case string(b) == "true":
+		return "1", nil
+	case string(b) == "false":
+		return "0", nil
+	case b[0] == '{' || b[0] == '[':
+		return "", fmt.Errorf("unexpected %s", b)
+	}
+
+	return string(b), nil
+}
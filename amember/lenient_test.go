@@ -0,0 +1,167 @@
+package amember
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLenientInt(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    Int
+		wantErr bool
+	}{
+		{in: `1`, want: 1},
+		{in: `"1"`, want: 1},
+		{in: `" 42 "`, want: 42},
+		{in: `"-3"`, want: -3},
+		{in: `"1.00"`, want: 1},
+		{in: `true`, want: 1},
+		{in: `false`, want: 0},
+		{in: `null`, want: 0},
+		{in: `""`, want: 0},
+		{in: `"abc"`, wantErr: true},
+		{in: `[1]`, wantErr: true},
+		{in: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+
+		got := Int(-1)
+		err := json.Unmarshal([]byte(tt.in), &got)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Int %s = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("Int %s = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLenientFloat(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    Float
+		wantErr bool
+	}{
+		{in: `1.5`, want: 1.5},
+		{in: `"1.5"`, want: 1.5},
+		{in: `1`, want: 1},
+		{in: `true`, want: 1},
+		{in: `null`, want: 0},
+		{in: `""`, want: 0},
+		{in: `"x"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+
+		got := Float(-1)
+		err := json.Unmarshal([]byte(tt.in), &got)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Float %s = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("Float %s = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLenientString(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    String
+		wantErr bool
+	}{
+		{in: `"abc"`, want: "abc"},
+		{in: `1`, want: "1"},
+		{in: `1.50`, want: "1.50"},
+		{in: `true`, want: "1"},
+		{in: `false`, want: "0"},
+		{in: `null`, want: ""},
+		{in: `""`, want: ""},
+		{in: `["a"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+
+		got := String("unset")
+		err := json.Unmarshal([]byte(tt.in), &got)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("String %s = %q, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("String %s = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLenientBool(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    Bool
+		wantErr bool
+	}{
+		{in: `true`, want: true},
+		{in: `false`, want: false},
+		{in: `1`, want: true},
+		{in: `0`, want: false},
+		{in: `"1"`, want: true},
+		{in: `"0"`, want: false},
+		{in: `"true"`, want: true},
+		{in: `"FALSE"`, want: false},
+		{in: `2`, want: true},
+		{in: `null`, want: false},
+		{in: `""`, want: false},
+		{in: `"yes"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+
+		got := Bool(!tt.want)
+		err := json.Unmarshal([]byte(tt.in), &got)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Bool %s = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("Bool %s = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeRecordMixedTypes(t *testing.T) {
+
+	raw := json.RawMessage(`{"user_id":"12","login":"jdoe","is_approved":1,"unsubscribed":"0","aff_id":null,"saved_form_id":"","status":"1"}`)
+
+	u, err := decodeRecord[User]("0", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.UserID != 12 || u.Login != "jdoe" || !bool(u.IsApproved) || bool(u.Unsubscribed) || u.AffID != 0 || u.SavedFormID != 0 || u.Status != UserActive {
+		t.Errorf("decodeRecord = %+v", u)
+	}
+}
@@ -0,0 +1,26 @@
+package amember
+
+import (
+	"log/slog"
+)
+
+// Logger is the logger used by the client. Every message comes with a list of alternating keys and values,
+// e.g. "endpoint", "users", "page", 3.
+//
+// A *slog.Logger satisfies Logger as is; use the gologadapter package to keep logging through golog.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+var _ Logger = (*slog.Logger)(nil)
+
+// NopLogger is a Logger that discards everything; it is the default logger of the client
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, args ...any) {}
+func (NopLogger) Info(msg string, args ...any)  {}
+func (NopLogger) Warn(msg string, args ...any)  {}
+func (NopLogger) Error(msg string, args ...any) {}
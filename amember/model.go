@@ -1,10 +1,16 @@
 package amember
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/araddon/dateparse"
 )
 
 type DBUser struct {
@@ -20,8 +26,8 @@ type DBUser struct {
 	Email              string       `json:"email"`
 	IAgree             int          `json:"i_agree"`
 	IsAffiliate        int          `json:"is_affiliate"`
-	IsApproved         int          `json:"is_approved"`
-	IsLocked           int          `json:"is_locked"`
+	IsApproved         bool         `json:"is_approved"`
+	IsLocked           LockStatus   `json:"is_locked"`
 	Lang               string       `json:"lang"`
 	LastIP             string       `json:"last_ip"`
 	LastLogin          sql.NullTime `json:"last_login"`
@@ -40,10 +46,10 @@ type DBUser struct {
 	SavedFormID        int          `json:"saved_form_id"`
 	SignupEmailSent    int          `json:"signup_email_sent"`
 	State              string       `json:"state"`
-	Status             int          `json:"status"`
+	Status             UserStatus   `json:"status"`
 	Street             string       `json:"street"`
 	Street2            string       `json:"street2"`
-	Unsubscribed       int          `json:"unsubscribed"`
+	Unsubscribed       bool         `json:"unsubscribed"`
 	UserAgent          string       `json:"user_agent"`
 	UserID             int          `json:"user_id"`
 	Zip                string       `json:"zip"`
@@ -72,138 +78,138 @@ type ViewUser struct {
 	TotalMonths             int          `json:"total_months"`
 	TotalDays               int          `json:"total_days"`
 	TotalDaysExcludingTrial int          `json:"total_days_excluding_trial"`
-	TotalPayments           float32      `json:"total_payments"`
+	TotalPayments           Money        `json:"total_payments"`
 	FirstPayment            sql.NullTime `json:"first_payment"`
 	LastPayment             sql.NullTime `json:"last_payment"`
 	HowDidYouHear           string       `json:"how_did_you_hear"`
 	PreferredContactMethod  string       `json:"preferred_contact_method"`
 	PreferredContact        string       `json:"preferred_contact"`
-	PaymentsLast3Months     float32      `json:"payments_last_3_months"`
+	PaymentsLast3Months     Money        `json:"payments_last_3_months"`
 	IsTopPayingUser         string       `json:"is_top_paying_user"`
 	CancellationDate        sql.NullTime `json:"cancellation_date"`
 	LastUpdated             sql.NullTime `json:"last_updated"`
 }
 
 type User struct {
-	Added              CustomTime  `json:"added"`
-	AffAdded           interface{} `json:"aff_added"`
-	AffCustomRedirect  int         `json:"aff_custom_redirect"`
-	AffID              interface{} `json:"aff_id"`
-	AffPayoutType      interface{} `json:"aff_payout_type"`
-	City               string      `json:"city"`
-	Comment            string      `json:"comment"`
-	Country            string      `json:"country"`
-	DisableLockUntil   CustomTime  `json:"disable_lock_until"`
-	Email              string      `json:"email"`
-	IAgree             int         `json:"i_agree"`
-	IsAffiliate        int         `json:"is_affiliate"`
-	IsApproved         int         `json:"is_approved"`
-	IsLocked           int         `json:"is_locked"`
-	Lang               string      `json:"lang"`
-	LastIP             string      `json:"last_ip"`
-	LastLogin          CustomTime  `json:"last_login"`
-	LastSession        string      `json:"last_session"`
-	LastUserAgent      string      `json:"last_user_agent"`
-	Login              string      `json:"login"`
-	NameF              string      `json:"name_f"`
-	NameL              string      `json:"name_l"`
-	NeedSessionRefresh int         `json:"need_session_refresh"`
-	Pass               string      `json:"pass"`
-	PassDattm          CustomTime  `json:"pass_dattm"`
-	Phone              string      `json:"phone"`
-	RememberKey        string      `json:"remember_key"`
-	RemoteAddr         string      `json:"remote_addr"`
-	ResellerID         int         `json:"reseller_id"`
-	SavedFormID        int         `json:"saved_form_id"`
-	SignupEmailSent    int         `json:"signup_email_sent"`
-	State              string      `json:"state"`
-	Status             int         `json:"status"`
-	Street             string      `json:"street"`
-	Street2            string      `json:"street2"`
-	Unsubscribed       int         `json:"unsubscribed"`
-	UserAgent          string      `json:"user_agent"`
-	UserID             int         `json:"user_id"`
-	Zip                string      `json:"zip"`
+	Added              CustomTime `json:"added"`
+	AffAdded           CustomTime `json:"aff_added"`
+	AffCustomRedirect  Int        `json:"aff_custom_redirect"`
+	AffID              Int        `json:"aff_id"`
+	AffPayoutType      String     `json:"aff_payout_type"`
+	City               String     `json:"city"`
+	Comment            String     `json:"comment"`
+	Country            String     `json:"country"`
+	DisableLockUntil   CustomTime `json:"disable_lock_until"`
+	Email              String     `json:"email"`
+	IAgree             Int        `json:"i_agree"`
+	IsAffiliate        Int        `json:"is_affiliate"`
+	IsApproved         Bool       `json:"is_approved"`
+	IsLocked           LockStatus `json:"is_locked"`
+	Lang               String     `json:"lang"`
+	LastIP             String     `json:"last_ip"`
+	LastLogin          CustomTime `json:"last_login"`
+	LastSession        String     `json:"last_session"`
+	LastUserAgent      String     `json:"last_user_agent"`
+	Login              String     `json:"login"`
+	NameF              String     `json:"name_f"`
+	NameL              String     `json:"name_l"`
+	NeedSessionRefresh Int        `json:"need_session_refresh"`
+	Pass               String     `json:"pass"`
+	PassDattm          CustomTime `json:"pass_dattm"`
+	Phone              String     `json:"phone"`
+	RememberKey        String     `json:"remember_key"`
+	RemoteAddr         String     `json:"remote_addr"`
+	ResellerID         Int        `json:"reseller_id"`
+	SavedFormID        Int        `json:"saved_form_id"`
+	SignupEmailSent    Int        `json:"signup_email_sent"`
+	State              String     `json:"state"`
+	Status             UserStatus `json:"status"`
+	Street             String     `json:"street"`
+	Street2            String     `json:"street2"`
+	Unsubscribed       Bool       `json:"unsubscribed"`
+	UserAgent          String     `json:"user_agent"`
+	UserID             Int        `json:"user_id"`
+	Zip                String     `json:"zip"`
 	//StripeCcExpires    string      `json:"stripe_cc_expires"`
 	//StripeCcMasked string      `json:"stripe_cc_masked"`
 	//StripeToken    string      `json:"stripe_token"`
-	CompanyName    string     `json:"company_name"`
-	CompanyAddress string     `json:"company_address"`
-	TaxID          string     `json:"taxid"`
+	CompanyName    String     `json:"company_name"`
+	CompanyAddress String     `json:"company_address"`
+	TaxID          String     `json:"taxid"`
 	ExpiredAt      CustomTime `json:"expired_at"`
 }
 
 type Invoice struct {
-	InvoiceID         int           `json:"invoice_id"`
-	UserID            int           `json:"user_id"`
-	PaysysID          string        `json:"paysys_id"`
-	Currency          string        `json:"currency"`
-	FirstSubtotal     float32       `json:"first_subtotal"`
-	FirstDiscount     float32       `json:"first_discount"`
-	FirstTax          float32       `json:"first_tax"`
-	FirstShipping     float32       `json:"first_shipping"`
-	FirstTotal        float32       `json:"first_total"`
-	FirstPeriod       string        `json:"first_period"`
-	RebillTimes       int           `json:"rebill_times"`
-	SecondSubtotal    float32       `json:"second_subtotal"`
-	SecondDiscount    float32       `json:"second_discount"`
-	SecondTax         float32       `json:"second_tax"`
-	SecondShipping    float32       `json:"second_shipping"`
-	SecondTotal       float32       `json:"second_total"`
-	SecondPeriod      string        `json:"second_period"`
-	TaxRate           interface{}   `json:"tax_rate"`
-	TaxType           interface{}   `json:"tax_type"`
-	TaxTitle          interface{}   `json:"tax_title"`
-	Status            int           `json:"status"`
-	CouponID          int           `json:"coupon_id"`
-	CouponCode        string        `json:"coupon_code"`
-	DiscountFirst     float32       `json:"discount_first"`
-	DiscountSecond    float32       `json:"discount_second"`
-	IsConfirmed       int           `json:"is_confirmed"`
-	PublicID          string        `json:"public_id"`
-	InvoiceKey        string        `json:"invoice_key"`
+	InvoiceID         Int           `json:"invoice_id"`
+	UserID            Int           `json:"user_id"`
+	PaysysID          String        `json:"paysys_id"`
+	Currency          String        `json:"currency"`
+	FirstSubtotal     Money         `json:"first_subtotal"`
+	FirstDiscount     Money         `json:"first_discount"`
+	FirstTax          Money         `json:"first_tax"`
+	FirstShipping     Money         `json:"first_shipping"`
+	FirstTotal        Money         `json:"first_total"`
+	FirstPeriod       Period        `json:"first_period"`
+	RebillTimes       Int           `json:"rebill_times"`
+	SecondSubtotal    Money         `json:"second_subtotal"`
+	SecondDiscount    Money         `json:"second_discount"`
+	SecondTax         Money         `json:"second_tax"`
+	SecondShipping    Money         `json:"second_shipping"`
+	SecondTotal       Money         `json:"second_total"`
+	SecondPeriod      Period        `json:"second_period"`
+	TaxRate           Float         `json:"tax_rate"`
+	TaxType           Int           `json:"tax_type"`
+	TaxTitle          String        `json:"tax_title"`
+	Status            InvoiceStatus `json:"status"`
+	CouponID          Int           `json:"coupon_id"`
+	CouponCode        String        `json:"coupon_code"`
+	DiscountFirst     Money         `json:"discount_first"`
+	DiscountSecond    Money         `json:"discount_second"`
+	IsConfirmed       Int           `json:"is_confirmed"`
+	PublicID          String        `json:"public_id"`
+	InvoiceKey        String        `json:"invoice_key"`
 	TmAdded           *CustomTime   `json:"tm_added"`
 	TmStarted         *CustomTime   `json:"tm_started"`
 	TmCancelled       *CustomTime   `json:"tm_cancelled"`
 	RebillDate        *CustomTime   `json:"rebill_date"`
 	DueDate           *CustomTime   `json:"due_date"`
-	Terms             interface{}   `json:"terms"`
-	Comment           interface{}   `json:"comment"`
-	BaseCurrencyMulti string        `json:"base_currency_multi"`
-	SavedFormID       int           `json:"saved_form_id"`
-	AffID             interface{}   `json:"aff_id"`
-	KeywordID         interface{}   `json:"keyword_id"`
-	RemoteAddr        interface{}   `json:"remote_addr"`
+	Terms             String        `json:"terms"`
+	Comment           String        `json:"comment"`
+	BaseCurrencyMulti String        `json:"base_currency_multi"`
+	SavedFormID       Int           `json:"saved_form_id"`
+	AffID             Int           `json:"aff_id"`
+	KeywordID         Int           `json:"keyword_id"`
+	RemoteAddr        String        `json:"remote_addr"`
 	Nested            InvoiceNested `json:"nested"`
 }
 
 type InvoiceNested struct {
-	Access          []Access  `json:"access"`
-	InvoiceItems    []Item    `json:"invoice-items"`
-	InvoicePayments []Payment `json:"invoice-payments"`
+	Access          []Access      `json:"access"`
+	InvoiceItems    []InvoiceItem `json:"invoice-items"`
+	InvoicePayments []Payment     `json:"invoice-payments"`
 }
 
 type Access struct {
-	AccessID           int       `json:"access_id"`
-	InvoiceID          int       `json:"invoice_id"`
-	InvoicePublicID    string    `json:"invoice_public_id"`
-	InvoicePaymentID   int       `json:"invoice_payment_id"`
-	InvoiceItemID      int       `json:"invoice_item_id"`
-	UserID             int       `json:"user_id"`
-	ProductID          int       `json:"product_id"`
-	TransactionID      string    `json:"transaction_id"`
-	BeginDate          time.Time `json:"begin_date"`
-	ExpireDate         time.Time `json:"expire_date"`
-	Qty                int       `json:"qty"`
-	Comment            string    `json:"comment"`
-	ProductTitle       string    `json:"product_title"`
-	Status             bool      `json:"status"`
-	ProductDescription string    `json:"product_description"`
-	Spend              float32   `json:"spend"`
-	SpendCoveredByPlan float32   `json:"spend_covered_by_plan"`
-	Overage            float32   `json:"overage"`
-	ProjectedSpend     float32   `json:"projected_spend"`
-	ProjectedOverage   float32   `json:"projected_overage"`
+	AccessID           Int          `json:"access_id"`
+	InvoiceID          Int          `json:"invoice_id"`
+	InvoicePublicID    String       `json:"invoice_public_id"`
+	InvoicePaymentID   Int          `json:"invoice_payment_id"`
+	InvoiceItemID      Int          `json:"invoice_item_id"`
+	UserID             Int          `json:"user_id"`
+	ProductID          Int          `json:"product_id"`
+	TransactionID      String       `json:"transaction_id"`
+	BeginDate          CustomTime   `json:"begin_date"`
+	ExpireDate         CustomTime   `json:"expire_date"`
+	Qty                Int          `json:"qty"`
+	Comment            String       `json:"comment"`
+	ProductTitle       String       `json:"product_title"`
+	Status             AccessStatus `json:"status"`
+	ProductDescription String       `json:"product_description"`
+	Spend              Money        `json:"spend"`
+	SpendCoveredByPlan Money        `json:"spend_covered_by_plan"`
+	Overage            Money        `json:"overage"`
+	ProjectedSpend     Money        `json:"projected_spend"`
+	ProjectedOverage   Money        `json:"projected_overage"`
 }
 
 type DBAccess struct {
@@ -220,112 +226,283 @@ type DBAccess struct {
 	Qty                int            `json:"qty"`
 	Comment            string         `json:"comment"`
 	ProductTitle       string         `json:"product_title"`
-	Status             bool           `json:"status"`
+	Status             AccessStatus   `json:"status"`
 	ProductDescription string         `json:"product_description"`
-	Spend              float32        `json:"spend"`
-	SpendCoveredByPlan float32        `json:"spend_covered_by_plan"`
-	Overage            float32        `json:"overage"`
-	ProjectedSpend     float32        `json:"projected_spend"`
-	ProjectedOverage   float32        `json:"projected_overage"`
+	Spend              Money          `json:"spend"`
+	SpendCoveredByPlan Money          `json:"spend_covered_by_plan"`
+	Overage            Money          `json:"overage"`
+	ProjectedSpend     Money          `json:"projected_spend"`
+	ProjectedOverage   Money          `json:"projected_overage"`
 }
 
 type Payment struct {
-	ConversionTrackDone    int       `json:"conversion-track-done"`
-	GoogleAnalyticsDone    int       `json:"google-analytics-done"`
-	InvoicePaymentID       int       `json:"invoice_payment_id"`
-	InvoiceID              int       `json:"invoice_id"`
-	InvoicePublicID        string    `json:"invoice_public_id"`
-	UserID                 int       `json:"user_id"`
-	PaysysID               string    `json:"paysys_id"`
-	ReceiptID              string    `json:"receipt_id"`
-	TransactionID          string    `json:"transaction_id"`
-	Dattm                  time.Time `json:"dattm"`
-	Currency               string    `json:"currency"`
-	Amount                 float32   `json:"amount"`
-	Discount               float32   `json:"discount"`
-	Tax                    float32   `json:"tax"`
-	Shipping               float32   `json:"shipping"`
-	RefundDattm            time.Time `json:"refund_dattm"`
-	RefundAmount           float32   `json:"refund_amount"`
-	BaseCurrencyMulti      float32   `json:"base_currency_multi"`
-	DisplayInvoiceID       string    `json:"display_invoice_id"`
-	Username               string    `json:"username"`
-	PaymentItemDescription string    `json:"payment_item_description"`
-	PaymentItemTitle       string    `json:"payment_item_title"`
-	Refunded               bool      `json:"refunded"`
+	ConversionTrackDone    Int        `json:"conversion-track-done"`
+	GoogleAnalyticsDone    Int        `json:"google-analytics-done"`
+	InvoicePaymentID       Int        `json:"invoice_payment_id"`
+	InvoiceID              Int        `json:"invoice_id"`
+	InvoicePublicID        String     `json:"invoice_public_id"`
+	UserID                 Int        `json:"user_id"`
+	PaysysID               String     `json:"paysys_id"`
+	ReceiptID              String     `json:"receipt_id"`
+	TransactionID          String     `json:"transaction_id"`
+	Dattm                  CustomTime `json:"dattm"`
+	Currency               String     `json:"currency"`
+	Amount                 Money      `json:"amount"`
+	Discount               Money      `json:"discount"`
+	Tax                    Money      `json:"tax"`
+	Shipping               Money      `json:"shipping"`
+	RefundDattm            CustomTime `json:"refund_dattm"`
+	RefundAmount           Money      `json:"refund_amount"`
+	BaseCurrencyMulti      Float      `json:"base_currency_multi"`
+	DisplayInvoiceID       String     `json:"display_invoice_id"`
+	Username               String     `json:"username"`
+	PaymentItemDescription String     `json:"payment_item_description"`
+	PaymentItemTitle       String     `json:"payment_item_title"`
+	Refunded               Bool       `json:"refunded"`
 }
 
 type Product struct {
-	CartDescription      string     `json:"cart_description"`
-	Comment              string     `json:"comment"`
-	Currency             string     `json:"currency"`
-	DefaultBillingPlanID int        `json:"default_billing_plan_id"`
-	Description          string     `json:"description"`
-	Img                  int        `json:"img"`
-	ImgCartPath          string     `json:"img_cart_path"`
-	ImgDetailPath        string     `json:"img_detail_path"`
-	ImgOrigPath          string     `json:"img_orig_path"`
-	ImgPath              string     `json:"img_path"`
-	IsArchived           int        `json:"is_archived"`
-	IsDisabled           int        `json:"is_disabled"`
-	IsTangible           int        `json:"is_tangible"`
-	MetaDescription      string     `json:"meta_description"`
-	MetaKeywords         string     `json:"meta_keywords"`
-	MetaRobots           string     `json:"meta_robots"`
-	MetaTitle            string     `json:"meta_title"`
-	Path                 string     `json:"path"`
-	PaysysID             string     `json:"paysys_id"`
-	PreventIfOther       string     `json:"prevent_if_other"`
-	ProductID            int        `json:"product_id"`
-	RenewalGroup         string     `json:"renewal_group"`
-	RequireOther         string     `json:"require_other"`
-	SortOrder            int        `json:"sort_order"`
+	CartDescription      String     `json:"cart_description"`
+	Comment              String     `json:"comment"`
+	Currency             String     `json:"currency"`
+	DefaultBillingPlanID Int        `json:"default_billing_plan_id"`
+	Description          String     `json:"description"`
+	Img                  Int        `json:"img"`
+	ImgCartPath          String     `json:"img_cart_path"`
+	ImgDetailPath        String     `json:"img_detail_path"`
+	ImgOrigPath          String     `json:"img_orig_path"`
+	ImgPath              String     `json:"img_path"`
+	IsArchived           Int        `json:"is_archived"`
+	IsDisabled           Int        `json:"is_disabled"`
+	IsTangible           Int        `json:"is_tangible"`
+	MetaDescription      String     `json:"meta_description"`
+	MetaKeywords         String     `json:"meta_keywords"`
+	MetaRobots           String     `json:"meta_robots"`
+	MetaTitle            String     `json:"meta_title"`
+	Path                 String     `json:"path"`
+	PaysysID             String     `json:"paysys_id"`
+	PreventIfOther       String     `json:"prevent_if_other"`
+	ProductID            Int        `json:"product_id"`
+	RenewalGroup         String     `json:"renewal_group"`
+	RequireOther         String     `json:"require_other"`
+	SortOrder            Int        `json:"sort_order"`
 	StartDate            CustomTime `json:"start_date"`
 	StartDateFixed       CustomTime `json:"start_date_fixed"`
-	Tags                 string     `json:"tags"`
-	TaxDigital           string     `json:"tax_digital"`
-	TaxGroup             string     `json:"tax_group"`
-	TaxRateGroup         string     `json:"tax_rate_group"`
-	ThanksRedirectURL    string     `json:"thanks_redirect_url"`
-	Title                string     `json:"title"`
-	TrialGroup           string     `json:"trial_group"`
-	URL                  string     `json:"url"`
+	Tags                 String     `json:"tags"`
+	TaxDigital           String     `json:"tax_digital"`
+	TaxGroup             String     `json:"tax_group"`
+	TaxRateGroup         String     `json:"tax_rate_group"`
+	ThanksRedirectURL    String     `json:"thanks_redirect_url"`
+	Title                String     `json:"title"`
+	TrialGroup           String     `json:"trial_group"`
+	URL                  String     `json:"url"`
 }
 
+// Item is an invoice item with the raw values sent by aMember.
+//
+// Deprecated: use InvoiceItem, which is what InvoiceNested.InvoiceItems holds.
 type Item struct {
-	BillingPlanData string      `json:"billing_plan_data"`
-	BillingPlanID   string      `json:"billing_plan_id"`
-	Currency        string      `json:"currency"`
-	FirstDiscount   string      `json:"first_discount"`
-	FirstPeriod     string      `json:"first_period"`
-	FirstPrice      string      `json:"first_price"`
-	FirstShipping   string      `json:"first_shipping"`
-	FirstTax        string      `json:"first_tax"`
-	FirstTotal      string      `json:"first_total"`
-	InvoiceID       string      `json:"invoice_id"`
-	InvoiceItemID   int64       `json:"invoice_item_id"`
-	InvoicePublicID string      `json:"invoice_public_id"`
-	IsCountable     string      `json:"is_countable"`
-	IsTangible      interface{} `json:"is_tangible"`
-	ItemDescription string      `json:"item_description"`
-	ItemID          string      `json:"item_id"`
-	ItemTitle       string      `json:"item_title"`
-	ItemType        string      `json:"item_type"`
-	Option1         interface{} `json:"option1"`
-	Option2         interface{} `json:"option2"`
-	Option3         interface{} `json:"option3"`
+	BillingPlanData String      `json:"billing_plan_data"`
+	BillingPlanID   String      `json:"billing_plan_id"`
+	Currency        String      `json:"currency"`
+	FirstDiscount   String      `json:"first_discount"`
+	FirstPeriod     Period      `json:"first_period"`
+	FirstPrice      String      `json:"first_price"`
+	FirstShipping   String      `json:"first_shipping"`
+	FirstTax        String      `json:"first_tax"`
+	FirstTotal      String      `json:"first_total"`
+	InvoiceID       String      `json:"invoice_id"`
+	InvoiceItemID   Int         `json:"invoice_item_id"`
+	InvoicePublicID String      `json:"invoice_public_id"`
+	IsCountable     String      `json:"is_countable"`
+	IsTangible      Int         `json:"is_tangible"`
+	ItemDescription String      `json:"item_description"`
+	ItemID          String      `json:"item_id"`
+	ItemTitle       String      `json:"item_title"`
+	ItemType        String      `json:"item_type"`
+	Option1         String      `json:"option1"`
+	Option2         String      `json:"option2"`
+	Option3         String      `json:"option3"`
 	Options         interface{} `json:"options"`
-	Qty             string      `json:"qty"`
-	RebillTimes     string      `json:"rebill_times"`
-	SecondDiscount  string      `json:"second_discount"`
-	SecondPeriod    string      `json:"second_period"`
-	SecondPrice     string      `json:"second_price"`
-	SecondShipping  string      `json:"second_shipping"`
-	SecondTax       string      `json:"second_tax"`
-	SecondTotal     string      `json:"second_total"`
-	TaxGroup        string      `json:"tax_group"`
-	TaxRate         interface{} `json:"tax_rate"`
-	VariableQty     string      `json:"variable_qty"`
+	Qty             String      `json:"qty"`
+	RebillTimes     String      `json:"rebill_times"`
+	SecondDiscount  String      `json:"second_discount"`
+	SecondPeriod    Period      `json:"second_period"`
+	SecondPrice     String      `json:"second_price"`
+	SecondShipping  String      `json:"second_shipping"`
+	SecondTax       String      `json:"second_tax"`
+	SecondTotal     String      `json:"second_total"`
+	TaxGroup        String      `json:"tax_group"`
+	TaxRate         Float       `json:"tax_rate"`
+	VariableQty     String      `json:"variable_qty"`
+}
+
+// UnmarshalJSON decodes an invoice record, setting the invoice currency on its amounts and on the ones of its nested records
+func (inv *Invoice) UnmarshalJSON(b []byte) error {
+
+	type invoice Invoice
+
+	var v invoice
+
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	*inv = Invoice(v)
+
+	c := string(inv.Currency)
+	for _, m := range []*Money{&inv.FirstSubtotal, &inv.FirstDiscount, &inv.FirstTax, &inv.FirstShipping, &inv.FirstTotal,
+		&inv.SecondSubtotal, &inv.SecondDiscount, &inv.SecondTax, &inv.SecondShipping, &inv.SecondTotal,
+		&inv.DiscountFirst, &inv.DiscountSecond} {
+		*m = m.withCurrency(c)
+	}
+
+	for i := range inv.Nested.InvoicePayments {
+		inv.Nested.InvoicePayments[i].setCurrency(c)
+	}
+
+	for i := range inv.Nested.InvoiceItems {
+		inv.Nested.InvoiceItems[i].setCurrency(c)
+	}
+
+	for i := range inv.Nested.Access {
+		inv.Nested.Access[i].setCurrency(c)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON decodes a payment record, setting the payment currency on its amounts
+func (p *Payment) UnmarshalJSON(b []byte) error {
+
+	type payment Payment
+
+	var v payment
+
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	*p = Payment(v)
+	p.setCurrency(string(p.Currency))
+
+	return nil
+}
+
+func (p *Payment) setCurrency(c string) {
+
+	for _, m := range []*Money{&p.Amount, &p.Discount, &p.Tax, &p.Shipping, &p.RefundAmount} {
+		*m = m.withCurrency(c)
+	}
+}
+
+// setCurrency sets the currency of the amounts of an access record, which has none of its own
+func (a *Access) setCurrency(c string) {
+
+	for _, m := range []*Money{&a.Spend, &a.SpendCoveredByPlan, &a.Overage, &a.ProjectedSpend, &a.ProjectedOverage} {
+		*m = m.withCurrency(c)
+	}
+}
+
+// InvoiceItem is a record of /api/invoice-items, or of the invoice-items nested block of an invoice
+type InvoiceItem struct {
+	InvoiceItemID   Int         `json:"invoice_item_id"`
+	InvoiceID       Int         `json:"invoice_id"`
+	InvoicePublicID String      `json:"invoice_public_id"`
+	ItemID          Int         `json:"item_id"`
+	ItemType        String      `json:"item_type"`
+	ItemTitle       String      `json:"item_title"`
+	ItemDescription String      `json:"item_description"`
+	Qty             Int         `json:"qty"`
+	VariableQty     Bool        `json:"variable_qty"`
+	Currency        String      `json:"currency"`
+	FirstPrice      Money       `json:"first_price"`
+	FirstDiscount   Money       `json:"first_discount"`
+	FirstTax        Money       `json:"first_tax"`
+	FirstShipping   Money       `json:"first_shipping"`
+	FirstTotal      Money       `json:"first_total"`
+	FirstPeriod     Period      `json:"first_period"`
+	RebillTimes     Int         `json:"rebill_times"`
+	SecondPrice     Money       `json:"second_price"`
+	SecondDiscount  Money       `json:"second_discount"`
+	SecondTax       Money       `json:"second_tax"`
+	SecondShipping  Money       `json:"second_shipping"`
+	SecondTotal     Money       `json:"second_total"`
+	SecondPeriod    Period      `json:"second_period"`
+	IsCountable     Bool        `json:"is_countable"`
+	IsTangible      Bool        `json:"is_tangible"`
+	TaxGroup        String      `json:"tax_group"`
+	TaxRate         Float       `json:"tax_rate"`
+	BillingPlanID   Int         `json:"billing_plan_id"`
+	BillingPlanData String      `json:"billing_plan_data"`
+	Option1         String      `json:"option1"`
+	Option2         String      `json:"option2"`
+	Option3         String      `json:"option3"`
+	Options         ItemOptions `json:"options"`
+}
+
+// UnmarshalJSON decodes an invoice item record, setting the item currency on its amounts
+func (it *InvoiceItem) UnmarshalJSON(b []byte) error {
+
+	type invoiceItem InvoiceItem
+
+	var v invoiceItem
+
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	*it = InvoiceItem(v)
+	it.setCurrency(string(it.Currency))
+
+	return nil
+}
+
+func (it *InvoiceItem) setCurrency(c string) {
+
+	for _, m := range []*Money{&it.FirstPrice, &it.FirstDiscount, &it.FirstTax, &it.FirstShipping, &it.FirstTotal,
+		&it.SecondPrice, &it.SecondDiscount, &it.SecondTax, &it.SecondShipping, &it.SecondTotal} {
+		*m = m.withCurrency(c)
+	}
+}
+
+// ItemOptions are the options chosen for an invoice item, keyed by option name.
+// aMember sends them either as a JSON object, or as a string containing one; an empty list means no options.
+type ItemOptions map[string]interface{}
+
+func (o *ItemOptions) UnmarshalJSON(b []byte) error {
+
+	b = bytes.TrimSpace(b)
+
+	//options encoded in a string, e.g. "{\"color\":{\"value\":\"red\"}}"
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		b = []byte(strings.TrimSpace(s))
+	}
+
+	*o = ItemOptions{}
+
+	//null, "" and the empty PHP array
+	if len(b) == 0 || string(b) == "null" || string(b) == "[]" {
+		return nil
+	}
+
+	m := map[string]interface{}{}
+
+	err := json.Unmarshal(b, &m)
+	if err != nil {
+		return fmt.Errorf("options: %w", err)
+	}
+
+	*o = m
+
+	return nil
 }
 
 type APIResponseUser struct {
@@ -337,14 +514,24 @@ type CustomTime struct {
 }
 
 type Membership struct {
-	User     User     `json:"user`
+	User     User     `json:"user"`
 	Accesses []Access `json:"accesses"`
 }
 
 func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 
 	//remove any extra " from the date string
-	s := strings.Trim(string(b), "\"")
+	s, err := scalar(b)
+	if err != nil {
+		return fmt.Errorf("time: %w", err)
+	}
+
+	ct.Time = time.Time{}
+
+	//aMember uses empty and zero dates for "not set"
+	if s == "" || strings.HasPrefix(s, "0000-00-00") {
+		return nil
+	}
 
 	regex := regexp.MustCompile("[0-9]")
 	mask := regex.ReplaceAllString(s, "x")
@@ -369,12 +556,41 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 			return err
 		}
 		ct.Time = t
+	default:
+		t, err := dateparse.ParseAny(s)
+		if err != nil {
+			return err
+		}
+		ct.Time = t
 	}
 
 	return nil
 }
 
-func (ct *CustomTime) MarshalJSON() ([]byte, error) {
+func (ct CustomTime) MarshalJSON() ([]byte, error) {
+
+	if ct.Time.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return []byte(`"` + ct.Time.Format("2006-01-02 15:04:05") + `"`), nil
+}
+
+// Scan implements sql.Scanner, so that a CustomTime can be read from the aMember database
+func (ct *CustomTime) Scan(value interface{}) error {
+
+	switch v := value.(type) {
+	case nil:
+		ct.Time = time.Time{}
+		return nil
+	case time.Time:
+		ct.Time = v
+		return nil
+	case []byte:
+		return ct.UnmarshalJSON(strconv.AppendQuote(nil, string(v)))
+	case string:
+		return ct.UnmarshalJSON(strconv.AppendQuote(nil, v))
+	}
 
-	return []byte(ct.Time.Format("2006-01-02 15:04:05")), nil
+	return fmt.Errorf("cannot scan %T into CustomTime", value)
 }
@@ -0,0 +1,263 @@
+package amember
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of money in minor units, together with its ISO 4217 currency code.
+// aMember stores every amount as DECIMAL(12,2), so Amount is always in hundredths of the currency unit,
+// e.g. Money{Amount: 1999, Currency: "EUR"} is 19.99 EUR.
+//
+// In the REST models Currency is taken from the Currency field of the record the amount belongs to;
+// amounts read from the database views have no currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// ErrCurrencyMismatch is returned by the Money arithmetic when the two amounts have different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ParseMoney parses a decimal amount such as "19.99", "-5" or "1234.5" in the given currency.
+// Digits after the second decimal are rounded half away from zero.
+func ParseMoney(s string, currency string) (Money, error) {
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{Currency: currency}, nil
+	}
+
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	units, decimals, _ := strings.Cut(s, ".")
+	if units == "" {
+		units = "0"
+	}
+
+	u, err := strconv.ParseInt(units, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+
+	var minor int64
+	for i, c := range decimals {
+
+		if c < '0' || c > '9' {
+			return Money{}, fmt.Errorf("invalid amount %q", s)
+		}
+
+		switch {
+		case i < 2:
+			minor = minor*10 + int64(c-'0')
+		case i == 2 && c >= '5':
+			minor++
+		}
+	}
+
+	//a single decimal digit is tenths
+	if len(decimals) == 1 {
+		minor *= 10
+	}
+
+	amount := u*100 + minor
+	if neg {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Decimal returns the amount as a decimal string with two decimals, e.g. "19.99", without the currency
+func (m Money) Decimal() string {
+
+	sign := ""
+	a := m.Amount
+	if a < 0 {
+		sign = "-"
+		a = -a
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, a/100, a%100)
+}
+
+// String returns the amount followed by the currency, e.g. "19.99 EUR"
+func (m Money) String() string {
+
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+
+	return m.Decimal() + " " + m.Currency
+}
+
+// Float64 returns the amount in currency units. Use it for display and statistics only, never to sum amounts.
+func (m Money) Float64() float64 {
+
+	return float64(m.Amount) / 100
+}
+
+// IsZero reports whether the amount is zero, whatever the currency
+func (m Money) IsZero() bool {
+
+	return m.Amount == 0
+}
+
+// Add returns m+o. An empty currency is compatible with any other one.
+func (m Money) Add(o Money) (Money, error) {
+
+	c, err := m.currencyWith(o)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{Amount: m.Amount + o.Amount, Currency: c}, nil
+}
+
+// Sub returns m-o. An empty currency is compatible with any other one.
+func (m Money) Sub(o Money) (Money, error) {
+
+	return m.Add(o.Neg())
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns m multiplied by n, e.g. the price of an item times its quantity
+func (m Money) Mul(n int64) Money {
+
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Cmp compares m and o, returning -1, 0 or +1. An empty currency is compatible with any other one.
+func (m Money) Cmp(o Money) (int, error) {
+
+	if _, err := m.currencyWith(o); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// SumMoney returns the sum of amounts, that must all have the same currency
+func SumMoney(amounts ...Money) (Money, error) {
+
+	total := Money{}
+
+	for _, a := range amounts {
+
+		var err error
+		total, err = total.Add(a)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+
+	return total, nil
+}
+
+func (m Money) currencyWith(o Money) (string, error) {
+
+	switch {
+	case m.Currency == "":
+		return o.Currency, nil
+	case o.Currency == "" || o.Currency == m.Currency:
+		return m.Currency, nil
+	}
+
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+// withCurrency returns m in the given currency, unless it already has one
+func (m Money) withCurrency(currency string) Money {
+
+	if m.Currency == "" {
+		m.Currency = currency
+	}
+
+	return m
+}
+
+// UnmarshalJSON decodes an amount sent by aMember as a number, a decimal string, "" or null.
+// The currency is left untouched: it is set by the model from its Currency field.
+func (m *Money) UnmarshalJSON(b []byte) error {
+
+	s, err := scalar(b)
+	if err != nil {
+		return fmt.Errorf("money: %w", err)
+	}
+
+	v, err := ParseMoney(s, m.Currency)
+	if err != nil {
+		return fmt.Errorf("money: %w", err)
+	}
+
+	*m = v
+
+	return nil
+}
+
+// MarshalJSON encodes the amount as a decimal string, the way aMember sends it
+func (m Money) MarshalJSON() ([]byte, error) {
+
+	return json.Marshal(m.Decimal())
+}
+
+// Scan implements sql.Scanner for DECIMAL columns
+func (m *Money) Scan(value interface{}) error {
+
+	var s string
+
+	switch v := value.(type) {
+	case nil:
+		s = ""
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+
+	v, err := ParseMoney(s, m.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = v
+
+	return nil
+}
+
+// Value implements driver.Valuer, storing the amount as a decimal string
+func (m Money) Value() (driver.Value, error) {
+
+	return m.Decimal(), nil
+}
@@ -0,0 +1,138 @@
+package amember
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "19.99", want: 1999},
+		{in: "0", want: 0},
+		{in: "", want: 0},
+		{in: "-5", want: -500},
+		{in: "+5", want: 500},
+		{in: "1234.5", want: 123450},
+		{in: ".5", want: 50},
+		{in: "0.05", want: 5},
+		{in: " 7.10 ", want: 710},
+		{in: "1.004", want: 100},
+		{in: "1.005", want: 101},
+		{in: "1.0049", want: 100},
+		{in: "0.995", want: 100},
+		{in: "-1.005", want: -101},
+		{in: "-0.004", want: 0},
+		{in: "abc", wantErr: true},
+		{in: "1.2x", wantErr: true},
+		{in: "1,50", wantErr: true},
+	}
+
+	for _, tt := range tests {
+
+		got, err := ParseMoney(tt.in, "EUR")
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.in, err)
+			continue
+		}
+
+		if got.Amount != tt.want || got.Currency != "EUR" {
+			t.Errorf("ParseMoney(%q) = %d %s, want %d EUR", tt.in, got.Amount, got.Currency, tt.want)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{amount: 1999, want: "19.99"},
+		{amount: 5, want: "0.05"},
+		{amount: -5, want: "-0.05"},
+		{amount: -123450, want: "-1234.50"},
+		{amount: 0, want: "0.00"},
+	}
+
+	for _, tt := range tests {
+		if got := (Money{Amount: tt.amount}).Decimal(); got != tt.want {
+			t.Errorf("Money{%d}.Decimal() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+
+	tests := []struct {
+		a, b    Money
+		want    Money
+		wantErr error
+	}{
+		{a: Money{100, "EUR"}, b: Money{250, "EUR"}, want: Money{350, "EUR"}},
+		{a: Money{100, ""}, b: Money{250, "EUR"}, want: Money{350, "EUR"}},
+		{a: Money{100, "USD"}, b: Money{-250, ""}, want: Money{-150, "USD"}},
+		{a: Money{100, "EUR"}, b: Money{250, "USD"}, wantErr: ErrCurrencyMismatch},
+	}
+
+	for _, tt := range tests {
+
+		got, err := tt.a.Add(tt.b)
+
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%v.Add(%v) error = %v, want %v", tt.a, tt.b, err, tt.wantErr)
+			continue
+		}
+
+		if err == nil && got != tt.want {
+			t.Errorf("%v.Add(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: `"19.99"`, want: 1999},
+		{in: `19.99`, want: 1999},
+		{in: `5`, want: 500},
+		{in: `"0.1"`, want: 10},
+		{in: `null`, want: 0},
+		{in: `""`, want: 0},
+		{in: `"n/a"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+
+		var m Money
+		err := json.Unmarshal([]byte(tt.in), &m)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("unmarshal %s = %v, want an error", tt.in, m)
+			}
+			continue
+		}
+
+		if err != nil || m.Amount != tt.want {
+			t.Errorf("unmarshal %s = %d, %v, want %d", tt.in, m.Amount, err, tt.want)
+		}
+	}
+}
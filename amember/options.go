@@ -0,0 +1,126 @@
+package amember
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+// Option configures an Amember client
+type Option func(*Amember)
+
+// WithHTTPClient makes the client send the REST requests through c, e.g. to share a transport or to inject one in tests.
+// The dial and TLS handshake timeouts are ignored in this case.
+func WithHTTPClient(c *http.Client) Option {
+
+	return func(am *Amember) {
+		am.client = c
+	}
+}
+
+// WithDB makes the client query the aMember database through an already opened pool
+func WithDB(db *sql.DB) Option {
+
+	return func(am *Amember) {
+		am.DB = db
+	}
+}
+
+// WithDSN makes the client open, and ping, a MySQL connection pool to the aMember database
+func WithDSN(dsn string) Option {
+
+	return func(am *Amember) {
+		am.dsn = dsn
+	}
+}
+
+// WithLogger sets the logger used by the client
+func WithLogger(l Logger) Option {
+
+	return func(am *Amember) {
+		am.Logger = l
+	}
+}
+
+// WithTimeout sets the overall timeout of a single REST request, including reading the response body
+func WithTimeout(d time.Duration) Option {
+
+	return func(am *Amember) {
+		am.timeout = d
+	}
+}
+
+// WithDialTimeout sets the timeout for establishing the connection to aMember. Defaults to 30s.
+func WithDialTimeout(d time.Duration) Option {
+
+	return func(am *Amember) {
+		am.dialTimeout = d
+	}
+}
+
+// WithTLSHandshakeTimeout sets the timeout for the TLS handshake with aMember. Defaults to 10s.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+
+	return func(am *Amember) {
+		am.tlsHandshakeTimeout = d
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the REST requests
+func WithUserAgent(ua string) Option {
+
+	return func(am *Amember) {
+		am.userAgent = ua
+	}
+}
+
+// WithRetry makes the client retry transient REST failures according to the given policy
+func WithRetry(policy RetryPolicy) Option {
+
+	return func(am *Amember) {
+		am.retry = policy
+	}
+}
+
+// WithRateLimit limits the REST requests sent by the client to rps per second, allowing bursts of up to burst requests.
+// The limit is shared by all the goroutines using the client, and applies to retries too.
+func WithRateLimit(rps float64, burst int) Option {
+
+	return func(am *Amember) {
+		if rps <= 0 {
+			am.limiter = nil
+			return
+		}
+		am.limiter = newRateLimiter(rps, burst)
+	}
+}
+
+// WithMaxConcurrency caps the number of REST requests the client has in flight at the same time
+func WithMaxConcurrency(n int) Option {
+
+	return func(am *Amember) {
+		if n <= 0 {
+			am.inflight = nil
+			return
+		}
+		am.inflight = make(semaphore, n)
+	}
+}
+
+// WithParallelism makes the client request up to n pages of a REST collection at the same time when listing it.
+// Records are still delivered in page order. Defaults to 1, i.e. pages are requested one after the other.
+func WithParallelism(n int) Option {
+
+	return func(am *Amember) {
+		am.parallelism = n
+	}
+}
+
+// WithAPIKeyInQuery makes the client send the API key as the _key query parameter instead of the X-API-Key header,
+// for aMember installs that do not accept the header. The key is masked in logs and errors anyway.
+func WithAPIKeyInQuery() Option {
+
+	return func(am *Amember) {
+		am.keyInQuery = true
+	}
+}
@@ -0,0 +1,108 @@
+package amember
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// pageResult is the outcome of a page requested by a crawlParallel worker
+type pageResult struct {
+	number int
+	page   collectionPage
+	err    error
+}
+
+// crawlParallel requests the pages after p.Page, up to the last one according to total, with a pool of am.parallelism workers.
+// fn is called from the calling goroutine for every record, in page order, as soon as all the previous pages have been processed.
+// To bound memory, at most 2*am.parallelism pages are fetched ahead of the one being processed.
+func (am *Amember) crawlParallel(ctx context.Context, endpoint string, p Params, total int, fn func(k string, v json.RawMessage) error) error {
+
+	lastPage := (total - 1) / pageSize(p)
+	if lastPage <= p.Page {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	results := make(chan pageResult, am.parallelism)
+	window := make(semaphore, 2*am.parallelism)
+
+	//dispatch the page numbers, keeping at most len(window) pages fetched but not processed
+	go func() {
+		defer close(jobs)
+
+		for n := p.Page + 1; n <= lastPage; n++ {
+
+			if err := window.acquire(ctx); err != nil {
+				return
+			}
+
+			select {
+			case jobs <- n:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < am.parallelism; i++ {
+
+		go func() {
+			for n := range jobs {
+
+				pp := p
+				pp.Page = n
+
+				page, err := am.fetchPage(ctx, endpoint, pp)
+
+				select {
+				case results <- pageResult{number: n, page: page, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	//pages arrive in any order: keep the early ones until their turn comes
+	pending := make(map[int]pageResult)
+
+	for next := p.Page + 1; next <= lastPage; {
+
+		var r pageResult
+
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if r.err != nil {
+			return r.err
+		}
+
+		pending[r.number] = r
+
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+
+			delete(pending, next)
+			window.release()
+
+			err := r.page.each(fn)
+			if err != nil {
+				return fmt.Errorf("%s page %d: %w", endpoint, r.number, err)
+			}
+
+			next++
+		}
+	}
+
+	return nil
+}
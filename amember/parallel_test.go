@@ -0,0 +1,186 @@
+package amember_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paperclicks/gomember/amember"
+	"github.com/paperclicks/gomember/amember/amembertest"
+)
+
+// newUsersServer returns a server holding n users, with user_id from 1 to n
+func newUsersServer(t *testing.T, n int) *amembertest.Server {
+
+	f := amembertest.Fixtures{}
+	for i := 1; i <= n; i++ {
+		f.Users = append(f.Users, amember.User{UserID: amember.Int(i), Login: amember.String(fmt.Sprintf("user%02d", i))})
+	}
+
+	srv := amembertest.NewServer(amembertest.WithFixtures(f))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// crawlUsers crawls /api/users 5 users per page, and returns the user IDs in the order they were delivered
+func crawlUsers(am *amember.Amember, fn func(id int) error) ([]int, error) {
+
+	var ids []int
+
+	p := amember.Params{Count: 5, Order: amember.Order{Field: "user_id"}}
+
+	err := am.Crawl(context.Background(), "users", p, func(k string, v json.RawMessage) error {
+
+		u := amember.User{}
+		if err := json.Unmarshal(v, &u); err != nil {
+			return err
+		}
+
+		ids = append(ids, int(u.UserID))
+
+		if fn != nil {
+			return fn(int(u.UserID))
+		}
+
+		return nil
+	})
+
+	return ids, err
+}
+
+// checkNoCrawlers fails the test if crawlParallel goroutines are still running shortly after the crawl returned
+func checkNoCrawlers(t *testing.T) {
+
+	t.Helper()
+
+	buf := make([]byte, 1<<20)
+
+	for deadline := time.Now().Add(2 * time.Second); ; {
+
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		if !strings.Contains(stacks, "crawlParallel") {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("crawlParallel goroutines left running:\n%s", stacks)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCrawlParallel(t *testing.T) {
+
+	for _, parallelism := range []int{1, 2, 4, 16} {
+		t.Run(fmt.Sprintf("parallelism %d", parallelism), func(t *testing.T) {
+
+			srv := newUsersServer(t, 48)
+
+			am, err := srv.Client(amember.WithParallelism(parallelism))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//the first pages are slow, so that the following ones arrive before them
+			srv.Inject(amembertest.Fault{Path: "/api/users", Latency: 30 * time.Millisecond, Times: 3})
+
+			ids, err := crawlUsers(am, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ids) != 48 {
+				t.Fatalf("%d users, want 48", len(ids))
+			}
+
+			for i, id := range ids {
+				if id != i+1 {
+					t.Fatalf("users delivered in order %v, want by user_id", ids)
+				}
+			}
+
+			//one request per page
+			if got := srv.Requests(); got != 10 {
+				t.Errorf("%d requests, want 10", got)
+			}
+
+			checkNoCrawlers(t)
+		})
+	}
+}
+
+func TestCrawlParallelPageError(t *testing.T) {
+
+	srv := newUsersServer(t, 100)
+
+	am, err := srv.Client(amember.WithParallelism(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the first 5 pages are served, the 6th request fails, the following ones are served again
+	srv.Inject(amembertest.Fault{Path: "/api/users", Times: 5})
+	srv.Inject(amembertest.Fault{Path: "/api/users", Status: http.StatusInternalServerError, Times: 1})
+
+	ids, err := crawlUsers(am, nil)
+
+	var apiErr *amember.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("err = %v, want the 500 of the failed page", err)
+	}
+
+	//the pages before the failed one may be delivered, in order, but not all of them
+	if len(ids) >= 100 {
+		t.Errorf("%d users delivered, want the crawl to stop at the failed page", len(ids))
+	}
+
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("users delivered in order %v, want by user_id", ids)
+		}
+	}
+
+	checkNoCrawlers(t)
+}
+
+func TestCrawlParallelCallbackError(t *testing.T) {
+
+	srv := newUsersServer(t, 100)
+
+	am, err := srv.Client(amember.WithParallelism(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := errors.New("stop")
+
+	ids, err := crawlUsers(am, func(id int) error {
+		if id == 33 {
+			return stop
+		}
+		return nil
+	})
+
+	if !errors.Is(err, stop) {
+		t.Fatalf("err = %v, want the error of the callback", err)
+	}
+
+	if len(ids) != 33 {
+		t.Errorf("%d users delivered, want 33", len(ids))
+	}
+
+	//user 33 is on page 6: besides page 0, at most the 5 pages processed before it and a read-ahead window of 2*4 pages are requested
+	if got := srv.Requests(); got > 1+5+2*4 {
+		t.Errorf("%d requests, want at most %d", got, 1+5+2*4)
+	}
+
+	checkNoCrawlers(t)
+}
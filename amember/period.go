@@ -0,0 +1,142 @@
+package amember
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PeriodUnit is the unit of a billing Period
+type PeriodUnit string
+
+const (
+	PeriodDay      PeriodUnit = "d"
+	PeriodMonth    PeriodUnit = "m"
+	PeriodYear     PeriodUnit = "y"
+	PeriodLifetime PeriodUnit = "lifetime"
+	//PeriodFixed is a period ending at a fixed date, e.g. "2025-12-31"
+	PeriodFixed PeriodUnit = "fixed"
+)
+
+// Period is an aMember billing period, as used by the first_period and second_period fields:
+// a number of days, months or years ("30d", "1m", "1y"), "lifetime", or a fixed date ("2025-12-31").
+// The zero value is the empty period, for invoices without a second period.
+type Period struct {
+	Count int
+	Unit  PeriodUnit
+	//Date is the end date of a PeriodFixed period
+	Date time.Time
+}
+
+// ParsePeriod parses an aMember period string
+func ParsePeriod(s string) (Period, error) {
+
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch {
+	case s == "":
+		return Period{}, nil
+	case s == "lifetime":
+		return Period{Unit: PeriodLifetime}, nil
+	}
+
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return Period{Unit: PeriodFixed, Date: t}, nil
+	}
+
+	unit := PeriodUnit(s[len(s)-1:])
+
+	switch unit {
+	case PeriodDay, PeriodMonth, PeriodYear:
+	default:
+		return Period{}, fmt.Errorf("invalid period %q", s)
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return Period{}, fmt.Errorf("invalid period %q", s)
+	}
+
+	return Period{Count: n, Unit: unit}, nil
+}
+
+// String returns the period in the aMember format, so that ParsePeriod(p.String()) == p
+func (p Period) String() string {
+
+	switch p.Unit {
+	case "":
+		return ""
+	case PeriodLifetime:
+		return "lifetime"
+	case PeriodFixed:
+		return p.Date.Format("2006-01-02")
+	}
+
+	return strconv.Itoa(p.Count) + string(p.Unit)
+}
+
+// LifetimeDate is the date aMember uses as expiration of lifetime accesses
+var LifetimeDate = time.Date(2037, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// AddTo returns the end of the period starting at t, e.g. the next rebill date or the expected expire date of an access.
+// Months and years are added like aMember does, normalizing overflowing days (Jan 31 + 1m is Mar 3 or Mar 2).
+// A lifetime period ends at LifetimeDate, a fixed date period at its date, and the empty period at t itself.
+func (p Period) AddTo(t time.Time) time.Time {
+
+	switch p.Unit {
+	case PeriodDay:
+		return t.AddDate(0, 0, p.Count)
+	case PeriodMonth:
+		return t.AddDate(0, p.Count, 0)
+	case PeriodYear:
+		return t.AddDate(p.Count, 0, 0)
+	case PeriodLifetime:
+		return time.Date(LifetimeDate.Year(), LifetimeDate.Month(), LifetimeDate.Day(), 0, 0, 0, 0, t.Location())
+	case PeriodFixed:
+		return time.Date(p.Date.Year(), p.Date.Month(), p.Date.Day(), 0, 0, 0, 0, t.Location())
+	}
+
+	return t
+}
+
+// IsLifetime reports whether p never expires
+func (p Period) IsLifetime() bool {
+
+	return p.Unit == PeriodLifetime
+}
+
+// IsFixedDate reports whether p ends at a fixed date instead of lasting a number of days, months or years
+func (p Period) IsFixedDate() bool {
+
+	return p.Unit == PeriodFixed
+}
+
+// IsZero reports whether p is the empty period
+func (p Period) IsZero() bool {
+
+	return p.Unit == ""
+}
+
+func (p *Period) UnmarshalJSON(b []byte) error {
+
+	s, err := scalar(b)
+	if err != nil {
+		return fmt.Errorf("period: %w", err)
+	}
+
+	v, err := ParsePeriod(s)
+	if err != nil {
+		return err
+	}
+
+	*p = v
+
+	return nil
+}
+
+func (p Period) MarshalJSON() ([]byte, error) {
+
+	return json.Marshal(p.String())
+}
@@ -0,0 +1,100 @@
+package amember
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    Period
+		wantErr bool
+	}{
+		{in: "30d", want: Period{Count: 30, Unit: PeriodDay}},
+		{in: "1m", want: Period{Count: 1, Unit: PeriodMonth}},
+		{in: "12M", want: Period{Count: 12, Unit: PeriodMonth}},
+		{in: "1y", want: Period{Count: 1, Unit: PeriodYear}},
+		{in: "lifetime", want: Period{Unit: PeriodLifetime}},
+		{in: "2025-12-31", want: Period{Unit: PeriodFixed, Date: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)}},
+		{in: "", want: Period{}},
+		{in: "1w", wantErr: true},
+		{in: "m", wantErr: true},
+		{in: "-1d", wantErr: true},
+		{in: "2025-13-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+
+		got, err := ParsePeriod(tt.in)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePeriod(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePeriod(%q) = %#v, %v, want %#v", tt.in, got, err, tt.want)
+			continue
+		}
+
+		if tt.in != "12M" && got.String() != tt.in {
+			t.Errorf("ParsePeriod(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestPeriodAddTo(t *testing.T) {
+
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		rome = time.FixedZone("CET", 3600)
+	}
+
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		period Period
+		start  time.Time
+		want   time.Time
+	}{
+		{name: "days", period: Period{Count: 30, Unit: PeriodDay}, start: date(2024, 1, 15), want: date(2024, 2, 14)},
+		{name: "month", period: Period{Count: 1, Unit: PeriodMonth}, start: date(2024, 1, 15), want: date(2024, 2, 15)},
+		{name: "month overflow leap year", period: Period{Count: 1, Unit: PeriodMonth}, start: date(2024, 1, 31), want: date(2024, 3, 2)},
+		{name: "month overflow", period: Period{Count: 1, Unit: PeriodMonth}, start: date(2023, 1, 31), want: date(2023, 3, 3)},
+		{name: "months over year end", period: Period{Count: 3, Unit: PeriodMonth}, start: date(2023, 11, 30), want: date(2024, 3, 1)},
+		{name: "year from leap day", period: Period{Count: 1, Unit: PeriodYear}, start: date(2024, 2, 29), want: date(2025, 3, 1)},
+		{name: "lifetime", period: Period{Unit: PeriodLifetime}, start: date(2024, 5, 1), want: date(2037, 12, 31)},
+		{name: "lifetime keeps location", period: Period{Unit: PeriodLifetime}, start: time.Date(2024, 5, 1, 10, 0, 0, 0, rome), want: time.Date(2037, 12, 31, 0, 0, 0, 0, rome)},
+		{name: "fixed", period: Period{Unit: PeriodFixed, Date: date(2025, 6, 30)}, start: date(2024, 5, 1), want: date(2025, 6, 30)},
+		{name: "empty", period: Period{}, start: date(2024, 5, 1), want: date(2024, 5, 1)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.period.AddTo(tt.start); !got.Equal(tt.want) || got.Location() != tt.want.Location() {
+			t.Errorf("%s: %v.AddTo(%v) = %v, want %v", tt.name, tt.period, tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestPeriodJSON(t *testing.T) {
+
+	var p Period
+	if err := json.Unmarshal([]byte(`"3m"`), &p); err != nil || p != (Period{Count: 3, Unit: PeriodMonth}) {
+		t.Errorf(`unmarshal "3m" = %#v, %v`, p, err)
+	}
+
+	if err := json.Unmarshal([]byte(`null`), &p); err != nil || !p.IsZero() {
+		t.Errorf("unmarshal null = %#v, %v", p, err)
+	}
+
+	b, err := json.Marshal(Period{Unit: PeriodLifetime})
+	if err != nil || string(b) != `"lifetime"` {
+		t.Errorf("marshal lifetime = %s, %v", b, err)
+	}
+}
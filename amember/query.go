@@ -0,0 +1,227 @@
+package amember
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidQuery is returned, wrapped, by BuildWhereConditions for conditions or options that cannot become SQL
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Condition is a SQL condition on a column of a table or view, e.g. {Column: "email", Operator: "=", Values: []interface{}{"a@b.c"}}.
+//
+// Operator is one of =, <>, !=, >, >=, <, <=, LIKE, NOT LIKE, IN, NOT IN, BETWEEN, IS NULL, IS NOT NULL,
+// or AND, OR, NOT for a group of Conditions; And, Or and Not build the groups.
+type Condition struct {
+	Column   string
+	Operator string
+	Values   []interface{}
+	//Deprecated: SubQuery was interpolated into the SQL as is, it is rejected now
+	SubQuery string
+	//Conditions are the members of an AND, OR or NOT group
+	Conditions []Condition
+}
+
+// And returns a group satisfied when all the conditions are
+func And(conditions ...Condition) Condition {
+
+	return Condition{Operator: "AND", Conditions: conditions}
+}
+
+// Or returns a group satisfied when at least one of the conditions is
+func Or(conditions ...Condition) Condition {
+
+	return Condition{Operator: "OR", Conditions: conditions}
+}
+
+// Not returns a group satisfied when the conditions, joined with AND, are not
+func Not(conditions ...Condition) Condition {
+
+	return Condition{Operator: "NOT", Conditions: conditions}
+}
+
+// QueryOptions are the ORDER BY, LIMIT and OFFSET clauses added by BuildWhereConditions.
+// Zero values add no clause.
+type QueryOptions struct {
+	OrderBy []Order
+	Limit   int
+	//Offset requires a Limit
+	Offset int
+}
+
+// queryColumns are the columns that can be used in the conditions and in the ORDER BY, for each table or view
+var queryColumns = map[string]map[string]bool{
+	//users is the view of the users and of their subscriptions read by GetUsersFromView
+	"users":   jsonFields(reflect.TypeOf(ViewUser{})),
+	"am_user": jsonFields(reflect.TypeOf(DBUser{})),
+	"am_access": {
+		"access_id": true, "invoice_id": true, "invoice_public_id": true, "invoice_payment_id": true, "invoice_item_id": true, "user_id": true,
+		"product_id": true, "transaction_id": true, "begin_date": true, "expire_date": true, "qty": true, "comment": true,
+	},
+}
+
+// operatorValues is the number of values each operator takes; -1 means at least one
+var operatorValues = map[string]int{
+	"=":           1,
+	"<>":          1,
+	"!=":          1,
+	">":           1,
+	">=":          1,
+	"<":           1,
+	"<=":          1,
+	"LIKE":        1,
+	"NOT LIKE":    1,
+	"IN":          -1,
+	"NOT IN":      -1,
+	"BETWEEN":     2,
+	"IS NULL":     0,
+	"IS NOT NULL": 0,
+}
+
+// BuildWhereConditions returns the WHERE, ORDER BY, LIMIT and OFFSET clauses selecting the rows of table that satisfy
+// all the conditions, together with the values of its placeholders, to append to a select and pass to Query:
+//
+//	where, values, err := BuildWhereConditions("users", []Condition{
+//		{Column: "subscriptionStatus", Operator: "IN", Values: []interface{}{"active", "trial"}},
+//		Or(Condition{Column: "cancellation_date", Operator: "IS NULL"}, Condition{Column: "cancellation_date", Operator: ">", Values: []interface{}{since}}),
+//	}, QueryOptions{OrderBy: []Order{{Field: "signup_date", Desc: true}}, Limit: 100})
+//
+// Columns must belong to table, and the values are always passed as placeholders.
+// An error wrapping ErrInvalidQuery is returned for unknown tables, columns and operators, wrong numbers of values and empty groups.
+func BuildWhereConditions(table string, conditions []Condition, opts QueryOptions) (string, []interface{}, error) {
+
+	columns, ok := queryColumns[table]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown table %s", ErrInvalidQuery, table)
+	}
+
+	clauses := []string{}
+	values := []interface{}{}
+
+	if len(conditions) > 0 {
+
+		where, err := buildGroup(columns, "AND", conditions, &values)
+		if err != nil {
+			return "", nil, err
+		}
+
+		clauses = append(clauses, "WHERE "+where)
+	}
+
+	if len(opts.OrderBy) > 0 {
+
+		order := []string{}
+		for _, o := range opts.OrderBy {
+
+			if !columns[o.Field] {
+				return "", nil, fmt.Errorf("%w: unknown column %s in order by", ErrInvalidQuery, o.Field)
+			}
+
+			dir := "ASC"
+			if o.Desc {
+				dir = "DESC"
+			}
+
+			order = append(order, fmt.Sprintf("`%s` %s", o.Field, dir))
+		}
+
+		clauses = append(clauses, "ORDER BY "+strings.Join(order, ", "))
+	}
+
+	switch {
+	case opts.Limit < 0 || opts.Offset < 0:
+		return "", nil, fmt.Errorf("%w: negative limit or offset", ErrInvalidQuery)
+	case opts.Offset > 0 && opts.Limit == 0:
+		return "", nil, fmt.Errorf("%w: offset without limit", ErrInvalidQuery)
+	}
+
+	if opts.Limit > 0 {
+		clauses = append(clauses, fmt.Sprintf("LIMIT %d", opts.Limit))
+	}
+
+	if opts.Offset > 0 {
+		clauses = append(clauses, fmt.Sprintf("OFFSET %d", opts.Offset))
+	}
+
+	return strings.Join(clauses, " "), values, nil
+}
+
+// buildGroup returns the conditions joined by op, adding their values to values
+func buildGroup(columns map[string]bool, op string, conditions []Condition, values *[]interface{}) (string, error) {
+
+	if len(conditions) == 0 {
+		return "", fmt.Errorf("%w: empty %s group", ErrInvalidQuery, op)
+	}
+
+	parts := make([]string, 0, len(conditions))
+
+	for _, c := range conditions {
+
+		part, err := buildCondition(columns, c, values)
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " "+op+" "), nil
+}
+
+// buildCondition returns a single condition, or a parenthesized group, adding its values to values
+func buildCondition(columns map[string]bool, c Condition, values *[]interface{}) (string, error) {
+
+	op := strings.ToUpper(strings.Join(strings.Fields(c.Operator), " "))
+
+	if c.SubQuery != "" {
+		return "", fmt.Errorf("%w: subqueries are not supported (column %s)", ErrInvalidQuery, c.Column)
+	}
+
+	switch op {
+	case "AND", "OR":
+
+		group, err := buildGroup(columns, op, c.Conditions, values)
+		if err != nil {
+			return "", err
+		}
+
+		return "(" + group + ")", nil
+
+	case "NOT":
+
+		group, err := buildGroup(columns, "AND", c.Conditions, values)
+		if err != nil {
+			return "", err
+		}
+
+		return "NOT (" + group + ")", nil
+	}
+
+	n, ok := operatorValues[op]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, c.Operator)
+	}
+
+	if !columns[c.Column] {
+		return "", fmt.Errorf("%w: unknown column %q", ErrInvalidQuery, c.Column)
+	}
+
+	if (n >= 0 && len(c.Values) != n) || (n < 0 && len(c.Values) == 0) {
+		return "", fmt.Errorf("%w: %s on %s with %d values", ErrInvalidQuery, op, c.Column, len(c.Values))
+	}
+
+	*values = append(*values, c.Values...)
+
+	switch op {
+	case "IN", "NOT IN":
+		return fmt.Sprintf("`%s` %s (%s)", c.Column, op, placeholders(len(c.Values))), nil
+	case "BETWEEN":
+		return fmt.Sprintf("`%s` BETWEEN ? AND ?", c.Column), nil
+	case "IS NULL", "IS NOT NULL":
+		return fmt.Sprintf("`%s` %s", c.Column, op), nil
+	}
+
+	return fmt.Sprintf("`%s` %s ?", c.Column, op), nil
+}
@@ -0,0 +1,209 @@
+package amember
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBuildWhereConditions(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		conditions []Condition
+		opts       QueryOptions
+		want       string
+		wantValues []interface{}
+	}{
+		{
+			name: "no conditions",
+			want: "",
+		},
+		{
+			name:       "equal",
+			conditions: []Condition{{Column: "email", Operator: "=", Values: []interface{}{"a@b.c"}}},
+			want:       "WHERE `email` = ?",
+			wantValues: []interface{}{"a@b.c"},
+		},
+		{
+			name:       "not equal",
+			conditions: []Condition{{Column: "userId", Operator: "<>", Values: []interface{}{1}}, {Column: "userId", Operator: "!=", Values: []interface{}{2}}},
+			want:       "WHERE `userId` <> ? AND `userId` != ?",
+			wantValues: []interface{}{1, 2},
+		},
+		{
+			name: "comparisons",
+			conditions: []Condition{
+				{Column: "total_days", Operator: ">", Values: []interface{}{1}},
+				{Column: "total_days", Operator: ">=", Values: []interface{}{2}},
+				{Column: "total_days", Operator: "<", Values: []interface{}{3}},
+				{Column: "total_days", Operator: "<=", Values: []interface{}{4}},
+			},
+			want:       "WHERE `total_days` > ? AND `total_days` >= ? AND `total_days` < ? AND `total_days` <= ?",
+			wantValues: []interface{}{1, 2, 3, 4},
+		},
+		{
+			name:       "like",
+			conditions: []Condition{{Column: "email", Operator: "like", Values: []interface{}{"%@example.com"}}},
+			want:       "WHERE `email` LIKE ?",
+			wantValues: []interface{}{"%@example.com"},
+		},
+		{
+			name:       "not like",
+			conditions: []Condition{{Column: "email", Operator: " not  like ", Values: []interface{}{"%@example.com"}}},
+			want:       "WHERE `email` NOT LIKE ?",
+			wantValues: []interface{}{"%@example.com"},
+		},
+		{
+			name:       "in",
+			conditions: []Condition{{Column: "subscriptionStatus", Operator: "IN", Values: []interface{}{"active", "trial"}}},
+			want:       "WHERE `subscriptionStatus` IN (?,?)",
+			wantValues: []interface{}{"active", "trial"},
+		},
+		{
+			name:       "not in",
+			conditions: []Condition{{Column: "userId", Operator: "NOT IN", Values: []interface{}{1, 2, 3}}},
+			want:       "WHERE `userId` NOT IN (?,?,?)",
+			wantValues: []interface{}{1, 2, 3},
+		},
+		{
+			name:       "between",
+			conditions: []Condition{{Column: "total_days", Operator: "BETWEEN", Values: []interface{}{10, 20}}},
+			want:       "WHERE `total_days` BETWEEN ? AND ?",
+			wantValues: []interface{}{10, 20},
+		},
+		{
+			name:       "is null",
+			conditions: []Condition{{Column: "cancellation_date", Operator: "IS NULL"}},
+			want:       "WHERE `cancellation_date` IS NULL",
+			wantValues: []interface{}{},
+		},
+		{
+			name:       "is not null",
+			conditions: []Condition{{Column: "cancellation_date", Operator: "is not null"}},
+			want:       "WHERE `cancellation_date` IS NOT NULL",
+			wantValues: []interface{}{},
+		},
+		{
+			name: "or group",
+			conditions: []Condition{
+				{Column: "subscriptionStatus", Operator: "=", Values: []interface{}{"active"}},
+				Or(Condition{Column: "cancellation_date", Operator: "IS NULL"}, Condition{Column: "cancellation_date", Operator: ">", Values: []interface{}{"2024-01-01"}}),
+			},
+			want:       "WHERE `subscriptionStatus` = ? AND (`cancellation_date` IS NULL OR `cancellation_date` > ?)",
+			wantValues: []interface{}{"active", "2024-01-01"},
+		},
+		{
+			name:       "not group",
+			conditions: []Condition{Not(Condition{Column: "email", Operator: "LIKE", Values: []interface{}{"%@test"}}, Condition{Column: "total_days", Operator: "<", Values: []interface{}{1}})},
+			want:       "WHERE NOT (`email` LIKE ? AND `total_days` < ?)",
+			wantValues: []interface{}{"%@test", 1},
+		},
+		{
+			name: "nested groups",
+			conditions: []Condition{
+				Or(
+					And(Condition{Column: "userId", Operator: "=", Values: []interface{}{1}}, Not(Condition{Column: "cancellation_date", Operator: "IS NULL"})),
+					Condition{Column: "userId", Operator: "IN", Values: []interface{}{2, 3}},
+				),
+			},
+			want:       "WHERE ((`userId` = ? AND NOT (`cancellation_date` IS NULL)) OR `userId` IN (?,?))",
+			wantValues: []interface{}{1, 2, 3},
+		},
+		{
+			name: "order by, limit and offset",
+			opts: QueryOptions{OrderBy: []Order{{Field: "signup_date", Desc: true}, {Field: "userId"}}, Limit: 100, Offset: 5},
+			want: "ORDER BY `signup_date` DESC, `userId` ASC LIMIT 100 OFFSET 5",
+		},
+		{
+			name:       "conditions and options",
+			conditions: []Condition{{Column: "userId", Operator: ">", Values: []interface{}{10}}},
+			opts:       QueryOptions{Limit: 10},
+			want:       "WHERE `userId` > ? LIMIT 10",
+			wantValues: []interface{}{10},
+		},
+	}
+
+	for _, tt := range tests {
+
+		got, values, err := BuildWhereConditions("users", tt.conditions, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+
+		if tt.wantValues == nil {
+			tt.wantValues = []interface{}{}
+		}
+
+		if !reflect.DeepEqual(values, tt.wantValues) {
+			t.Errorf("%s: values %v, want %v", tt.name, values, tt.wantValues)
+		}
+	}
+}
+
+func TestBuildWhereConditionsTables(t *testing.T) {
+
+	tests := []struct {
+		table  string
+		column string
+	}{
+		{table: "users", column: "subscriptionStatus"},
+		{table: "am_user", column: "login"},
+		{table: "am_access", column: "expire_date"},
+	}
+
+	for _, tt := range tests {
+
+		_, _, err := BuildWhereConditions(tt.table, []Condition{{Column: tt.column, Operator: "IS NULL"}}, QueryOptions{})
+		if err != nil {
+			t.Errorf("%s.%s: %v", tt.table, tt.column, err)
+		}
+	}
+}
+
+func TestBuildWhereConditionsErrors(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		table      string
+		conditions []Condition
+		opts       QueryOptions
+	}{
+		{name: "unknown table", table: "am_invoice"},
+		{name: "unknown column", conditions: []Condition{{Column: "password", Operator: "=", Values: []interface{}{"x"}}}},
+		{name: "column of another table", table: "am_access", conditions: []Condition{{Column: "email", Operator: "IS NULL"}}},
+		{name: "injected column", conditions: []Condition{{Column: "email` = 1 OR `1", Operator: "IS NULL"}}},
+		{name: "unknown operator", conditions: []Condition{{Column: "email", Operator: "REGEXP", Values: []interface{}{"x"}}}},
+		{name: "unknown order by column", opts: QueryOptions{OrderBy: []Order{{Field: "rand()"}}}},
+		{name: "missing value", conditions: []Condition{{Column: "email", Operator: "="}}},
+		{name: "too many values", conditions: []Condition{{Column: "email", Operator: "LIKE", Values: []interface{}{"a", "b"}}}},
+		{name: "between with one value", conditions: []Condition{{Column: "total_days", Operator: "BETWEEN", Values: []interface{}{1}}}},
+		{name: "in without values", conditions: []Condition{{Column: "userId", Operator: "IN"}}},
+		{name: "is null with a value", conditions: []Condition{{Column: "cancellation_date", Operator: "IS NULL", Values: []interface{}{1}}}},
+		{name: "empty and group", conditions: []Condition{And()}},
+		{name: "empty or group", conditions: []Condition{Or()}},
+		{name: "empty not group", conditions: []Condition{Not()}},
+		{name: "invalid condition in a group", conditions: []Condition{Or(Condition{Column: "email", Operator: "IS NULL"}, Condition{Column: "nope", Operator: "IS NULL"})}},
+		{name: "offset without limit", opts: QueryOptions{Offset: 10}},
+		{name: "negative limit", opts: QueryOptions{Limit: -1}},
+		{name: "negative offset", opts: QueryOptions{Limit: 10, Offset: -1}},
+		{name: "subquery", conditions: []Condition{{Column: "userId", Operator: "IN", SubQuery: "SELECT user_id FROM am_user"}}},
+	}
+
+	for _, tt := range tests {
+
+		if tt.table == "" {
+			tt.table = "users"
+		}
+
+		got, values, err := BuildWhereConditions(tt.table, tt.conditions, tt.opts)
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: got %q %v, %v, want an ErrInvalidQuery", tt.name, got, values, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package amember
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket: it holds up to burst tokens, refilled at rate tokens per second,
+// and every request consumes one token.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{rate: rps, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a token is available, or until ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+
+	for {
+		l.mu.Lock()
+
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+
+		//time needed to refill the missing fraction of token
+		d := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+
+		l.mu.Unlock()
+
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// semaphore caps the number of requests in flight
+type semaphore chan struct{}
+
+func (s semaphore) acquire(ctx context.Context) error {
+
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+
+	<-s
+}
+
+// throttle waits for the rate limiter and for a free request slot, if configured.
+// The returned function releases the slot and must be called once the request is over.
+func (am *Amember) throttle(ctx context.Context) (func(), error) {
+
+	if am.limiter != nil {
+		if err := am.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if am.inflight == nil {
+		return func() {}, nil
+	}
+
+	if err := am.inflight.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	return am.inflight.release, nil
+}
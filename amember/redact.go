@@ -0,0 +1,90 @@
+package amember
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const redacted = "***"
+
+// redactURL masks the value of the _key parameter of a URL
+func redactURL(rawURL string) string {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+	if !q.Has("_key") {
+		return rawURL
+	}
+
+	q.Set("_key", redacted)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// redactingLogger masks the API key, and any _key parameter, in the messages and attributes sent to Logger
+type redactingLogger struct {
+	Logger
+	secret string
+}
+
+func (l redactingLogger) Debug(msg string, args ...any) {
+	l.Logger.Debug(l.redact(msg), l.redactArgs(args)...)
+}
+func (l redactingLogger) Info(msg string, args ...any) {
+	l.Logger.Info(l.redact(msg), l.redactArgs(args)...)
+}
+func (l redactingLogger) Warn(msg string, args ...any) {
+	l.Logger.Warn(l.redact(msg), l.redactArgs(args)...)
+}
+func (l redactingLogger) Error(msg string, args ...any) {
+	l.Logger.Error(l.redact(msg), l.redactArgs(args)...)
+}
+
+func (l redactingLogger) redact(s string) string {
+
+	if strings.Contains(s, "_key=") {
+		s = redactURL(s)
+	}
+
+	if l.secret != "" {
+		s = strings.ReplaceAll(s, l.secret, redacted)
+	}
+
+	return s
+}
+
+func (l redactingLogger) redactArgs(args []any) []any {
+
+	out := make([]any, len(args))
+
+	for i, a := range args {
+
+		switch v := a.(type) {
+		case string:
+			out[i] = l.redact(v)
+		case error:
+			if s := l.redact(v.Error()); s != v.Error() {
+				out[i] = errors.New(s)
+				continue
+			}
+			out[i] = v
+		case fmt.Stringer:
+			if s := l.redact(v.String()); s != v.String() {
+				out[i] = s
+				continue
+			}
+			out[i] = v
+		default:
+			out[i] = v
+		}
+	}
+
+	return out
+}
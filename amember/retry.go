@@ -0,0 +1,122 @@
+package amember
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines how transient REST failures are retried: 5xx and 429 responses, timeouts and network errors.
+// The zero value disables retries.
+type RetryPolicy struct {
+	//MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	//InitialBackoff is the wait before the first retry; it is multiplied by Multiplier at every further retry
+	InitialBackoff time.Duration
+	//MaxBackoff caps the wait between two attempts, including the one requested by Retry-After
+	MaxBackoff time.Duration
+	Multiplier float64
+	//Jitter is the fraction of the backoff, between 0 and 1, that is randomized to spread the retries of concurrent clients
+	Jitter float64
+}
+
+// DefaultRetryPolicy is a reasonable policy for the nightly crawls: 5 attempts over about 15 seconds
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff returns the wait before the given retry, starting from 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(d)
+}
+
+// retryable reports whether a request that failed with err can be sent again.
+// Requests with a non idempotent method are only retried when aMember has certainly not processed them.
+// Nothing is retried once the caller's ctx is done, while the timeouts of a single attempt (e.g. the http.Client
+// Timeout set by WithTimeout) are retried like the other network errors.
+func retryable(ctx context.Context, method string, err error) bool {
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+
+		return apiErr.StatusCode >= 500 && idempotent(method)
+	}
+
+	//a deadline exceeded while ctx is still alive is the timeout of this attempt only
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return idempotent(method)
+	}
+
+	return false
+}
+
+func idempotent(method string) bool {
+
+	return method != http.MethodPost
+}
+
+// retryAfter parses the value of a Retry-After header, either in seconds or as an HTTP date
+func retryAfter(h string) time.Duration {
+
+	if h == "" {
+		return 0
+	}
+
+	if s, err := strconv.Atoi(h); err == nil {
+		return time.Duration(s) * time.Second
+	}
+
+	if t, err := http.ParseTime(h); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package amember
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 10: time.Second} {
+		if got := p.backoff(retry); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", retry, got, want)
+		}
+	}
+
+	//a multiplier below 1 keeps the backoff constant
+	p.Multiplier = 0
+	if got := p.backoff(3); got != 100*time.Millisecond {
+		t.Errorf("backoff(3) without multiplier = %s, want 100ms", got)
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("backoff(1) with 20%% jitter = %s, want between 80ms and 120ms", got)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timeout := &net.OpError{Op: "read", Err: &net.DNSError{IsTimeout: true}}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		err    error
+		want   bool
+	}{
+		{name: "GET 502", method: http.MethodGet, err: &APIError{StatusCode: 502}, want: true},
+		{name: "PUT 503", method: http.MethodPut, err: &APIError{StatusCode: 503}, want: true},
+		{name: "POST 502", method: http.MethodPost, err: &APIError{StatusCode: 502}},
+		{name: "GET 429", method: http.MethodGet, err: &APIError{StatusCode: 429}, want: true},
+		{name: "POST 429", method: http.MethodPost, err: &APIError{StatusCode: 429}, want: true},
+		{name: "GET 404", method: http.MethodGet, err: &APIError{StatusCode: 404}},
+		{name: "GET error payload", method: http.MethodGet, err: &APIError{StatusCode: 200}},
+		{name: "GET network error", method: http.MethodGet, err: timeout, want: true},
+		{name: "POST network error", method: http.MethodPost, err: timeout},
+		{name: "GET attempt deadline", method: http.MethodGet, err: context.DeadlineExceeded, want: true},
+		{name: "GET ctx done", ctx: cancelled, method: http.MethodGet, err: &APIError{StatusCode: 502}},
+		{name: "GET 429 ctx done", ctx: cancelled, method: http.MethodGet, err: &APIError{StatusCode: 429}},
+		{name: "GET other error", method: http.MethodGet, err: context.Canceled},
+	}
+
+	for _, tt := range tests {
+
+		if tt.ctx == nil {
+			tt.ctx = context.Background()
+		}
+
+		if got := retryable(tt.ctx, tt.method, tt.err); got != tt.want {
+			t.Errorf("%s: retryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+
+	tests := map[string]time.Duration{"": 0, "3": 3 * time.Second, "soon": 0}
+
+	for h, want := range tests {
+		if got := retryAfter(h); got != want {
+			t.Errorf("retryAfter(%q) = %s, want %s", h, got, want)
+		}
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := retryAfter(date); got < 58*time.Second || got > time.Minute {
+		t.Errorf("retryAfter(%q) = %s, want about a minute", date, got)
+	}
+}
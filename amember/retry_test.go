@@ -0,0 +1,186 @@
+package amember_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paperclicks/gomember/amember"
+	"github.com/paperclicks/gomember/amember/amembertest"
+)
+
+// fastRetry retries without waiting, so that the tests only count the attempts
+var fastRetry = amember.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}
+
+func TestRetry(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		method       string
+		status       int
+		times        int
+		policy       amember.RetryPolicy
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "GET 502 until it succeeds", method: http.MethodGet, status: http.StatusBadGateway, times: 2, policy: fastRetry, wantRequests: 3},
+		{name: "GET 503 beyond the attempts", method: http.MethodGet, status: http.StatusServiceUnavailable, times: 5, policy: fastRetry, wantErr: true, wantRequests: 3},
+		{name: "GET 429", method: http.MethodGet, status: http.StatusTooManyRequests, times: 1, policy: fastRetry, wantRequests: 2},
+		{name: "GET 400 is not retried", method: http.MethodGet, status: http.StatusBadRequest, times: 1, policy: fastRetry, wantErr: true, wantRequests: 1},
+		{name: "GET 502 without retry policy", method: http.MethodGet, status: http.StatusBadGateway, times: 1, wantErr: true, wantRequests: 1},
+		{name: "POST 429", method: http.MethodPost, status: http.StatusTooManyRequests, times: 1, policy: fastRetry, wantRequests: 2},
+		{name: "POST 502 is not retried", method: http.MethodPost, status: http.StatusBadGateway, times: 1, policy: fastRetry, wantErr: true, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			srv := amembertest.NewServer()
+			defer srv.Close()
+
+			am, err := srv.Client(amember.WithRetry(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			srv.Inject(amembertest.Fault{Path: "/api/users", Status: tt.status, Times: tt.times})
+
+			if tt.method == http.MethodPost {
+				_, err = am.CreateUser(context.Background(), amember.User{Login: "jdoe", Email: "jdoe@example.com"})
+			} else {
+				_, err = am.Count(context.Background(), "users", amember.Params{})
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+
+			var apiErr *amember.APIError
+			if tt.wantErr && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status) {
+				t.Errorf("err = %v, want an *APIError with status %d", err, tt.status)
+			}
+
+			if got := srv.Requests(); got != tt.wantRequests {
+				t.Errorf("%d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		maxBackoff time.Duration
+		min        time.Duration
+		max        time.Duration
+	}{
+		{name: "honoured", maxBackoff: 5 * time.Second, min: time.Second, max: 3 * time.Second},
+		{name: "capped by MaxBackoff", maxBackoff: 20 * time.Millisecond, max: 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			requests := 0
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+				requests++
+
+				if requests == 1 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				w.Write([]byte(`{"_total":0}`))
+			}))
+			defer srv.Close()
+
+			policy := amember.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: tt.maxBackoff}
+
+			am, err := amember.NewClient(srv.URL, "key", amember.WithRetry(policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+
+			_, err = am.Count(context.Background(), "users", amember.Params{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := time.Since(start); d < tt.min || d > tt.max {
+				t.Errorf("retried after %s, want between %s and %s", d, tt.min, tt.max)
+			}
+
+			if requests != 2 {
+				t.Errorf("%d requests, want 2", requests)
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenContextIsDone(t *testing.T) {
+
+	srv := amembertest.NewServer()
+	defer srv.Close()
+
+	policy := amember.RetryPolicy{MaxAttempts: 5, InitialBackoff: 10 * time.Second}
+
+	am, err := srv.Client(amember.WithRetry(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Inject(amembertest.Fault{Status: http.StatusBadGateway})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err = am.Count(ctx, "users", amember.Params{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("returned after %s, want as soon as ctx is done", d)
+	}
+
+	if got := srv.Requests(); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+
+	srv := amembertest.NewServer()
+	defer srv.Close()
+
+	//the timeout of a single attempt is retried, the next attempt succeeds
+	srv.Inject(amembertest.Fault{Latency: time.Second, Times: 1})
+
+	hc := srv.Server.Client()
+	hc.Timeout = 200 * time.Millisecond
+
+	am, err := srv.Client(amember.WithRetry(fastRetry), amember.WithHTTPClient(hc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = am.Count(context.Background(), "users", amember.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := srv.Requests(); got != 2 {
+		t.Errorf("%d requests, want 2", got)
+	}
+}
@@ -0,0 +1,170 @@
+package amember
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+)
+
+// UserStatus is the status of an aMember user, as stored in am_user.status
+type UserStatus int
+
+const (
+	//UserPending users never had an access
+	UserPending UserStatus = 0
+	//UserActive users have at least one access not expired yet
+	UserActive UserStatus = 1
+	//UserExpired users only have expired accesses
+	UserExpired UserStatus = 2
+)
+
+func (s UserStatus) String() string {
+
+	switch s {
+	case UserPending:
+		return "pending"
+	case UserActive:
+		return "active"
+	case UserExpired:
+		return "expired"
+	}
+
+	return "UserStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// InvoiceStatus is the status of an aMember invoice, as stored in am_invoice.status
+type InvoiceStatus int
+
+const (
+	InvoicePending            InvoiceStatus = 0
+	InvoicePaid               InvoiceStatus = 1
+	InvoiceRecurringActive    InvoiceStatus = 2
+	InvoiceRecurringCancelled InvoiceStatus = 3
+	InvoiceRecurringFailed    InvoiceStatus = 4
+	InvoiceRecurringFinished  InvoiceStatus = 5
+	InvoiceChargeback         InvoiceStatus = 7
+	InvoiceNotConfirmed       InvoiceStatus = 8
+)
+
+func (s InvoiceStatus) String() string {
+
+	switch s {
+	case InvoicePending:
+		return "pending"
+	case InvoicePaid:
+		return "paid"
+	case InvoiceRecurringActive:
+		return "recurring-active"
+	case InvoiceRecurringCancelled:
+		return "recurring-cancelled"
+	case InvoiceRecurringFailed:
+		return "recurring-failed"
+	case InvoiceRecurringFinished:
+		return "recurring-finished"
+	case InvoiceChargeback:
+		return "chargeback"
+	case InvoiceNotConfirmed:
+		return "not-confirmed"
+	}
+
+	return "InvoiceStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// AccessStatus tells whether an access record returned by the REST API is currently active
+type AccessStatus int
+
+const (
+	AccessInactive AccessStatus = 0
+	AccessActive   AccessStatus = 1
+)
+
+func (s AccessStatus) String() string {
+
+	switch s {
+	case AccessInactive:
+		return "inactive"
+	case AccessActive:
+		return "active"
+	}
+
+	return "AccessStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// LockStatus is the lock flag of an aMember user, as stored in am_user.is_locked
+type LockStatus int
+
+const (
+	Unlocked LockStatus = 0
+	Locked   LockStatus = 1
+	//AutoLockDisabled users are never locked automatically, e.g. for sharing their account
+	AutoLockDisabled LockStatus = -1
+)
+
+func (s LockStatus) String() string {
+
+	switch s {
+	case Unlocked:
+		return "unlocked"
+	case Locked:
+		return "locked"
+	case AutoLockDisabled:
+		return "auto-lock-disabled"
+	}
+
+	return "LockStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+func (s *UserStatus) UnmarshalJSON(b []byte) error    { return unmarshalEnum(b, (*int)(s)) }
+func (s *InvoiceStatus) UnmarshalJSON(b []byte) error { return unmarshalEnum(b, (*int)(s)) }
+func (s *AccessStatus) UnmarshalJSON(b []byte) error  { return unmarshalEnum(b, (*int)(s)) }
+func (s *LockStatus) UnmarshalJSON(b []byte) error    { return unmarshalEnum(b, (*int)(s)) }
+
+func (s *UserStatus) Scan(value interface{}) error    { return scanEnum(value, (*int)(s)) }
+func (s *InvoiceStatus) Scan(value interface{}) error { return scanEnum(value, (*int)(s)) }
+func (s *AccessStatus) Scan(value interface{}) error  { return scanEnum(value, (*int)(s)) }
+func (s *LockStatus) Scan(value interface{}) error    { return scanEnum(value, (*int)(s)) }
+
+func (s UserStatus) Value() (driver.Value, error)    { return int64(s), nil }
+func (s InvoiceStatus) Value() (driver.Value, error) { return int64(s), nil }
+func (s AccessStatus) Value() (driver.Value, error)  { return int64(s), nil }
+func (s LockStatus) Value() (driver.Value, error)    { return int64(s), nil }
+
+// unmarshalEnum decodes a status code sent as a number, a numeric string or a bool
+func unmarshalEnum(b []byte, v *int) error {
+
+	var i Int
+
+	err := i.UnmarshalJSON(b)
+	if err != nil {
+		return err
+	}
+
+	*v = int(i)
+
+	return nil
+}
+
+// scanEnum reads a status code from a database column
+func scanEnum(value interface{}, v *int) error {
+
+	switch t := value.(type) {
+	case nil:
+		*v = 0
+	case int64:
+		*v = int(t)
+	case []byte:
+		return unmarshalEnum(strconv.AppendQuote(nil, string(t)), v)
+	case string:
+		return unmarshalEnum(strconv.AppendQuote(nil, t), v)
+	case bool:
+		if t {
+			*v = 1
+		} else {
+			*v = 0
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into a status", value)
+	}
+
+	return nil
+}
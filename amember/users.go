@@ -0,0 +1,142 @@
+package amember
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// CreateUser creates a new user through /api/users and returns it as stored by aMember.
+// Only the non-zero fields of u are sent; u.Pass is the plain text password, aMember takes care of hashing it.
+func (am *Amember) CreateUser(ctx context.Context, u User) (User, error) {
+
+	form := structToForm(u)
+
+	//user_id is assigned by aMember
+	form.Del("user_id")
+
+	return am.writeUser(ctx, http.MethodPost, am.APIURL+"/api/users", form)
+}
+
+// UpdateUser sets the given fields, keyed by their aMember name (e.g. "email", "name_f"), on the user having the given user_id
+// and returns the updated user.
+func (am *Amember) UpdateUser(ctx context.Context, id int, fields map[string]string) (User, error) {
+
+	form := url.Values{}
+	for k, v := range fields {
+		form.Set(k, v)
+	}
+
+	return am.writeUser(ctx, http.MethodPut, fmt.Sprintf("%s/api/users/%d", am.APIURL, id), form)
+}
+
+// DeleteUser deletes the user having the given user_id
+func (am *Amember) DeleteUser(ctx context.Context, id int) error {
+
+	_, err := am.doRequest(ctx, http.MethodDelete, fmt.Sprintf("%s/api/users/%d", am.APIURL, id), nil)
+	if err != nil {
+		return fmt.Errorf("delete user %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// writeUser sends form to a users endpoint and decodes the user record returned by aMember
+func (am *Amember) writeUser(ctx context.Context, method string, url string, form url.Values) (User, error) {
+
+	response, err := am.doRequest(ctx, method, url, form)
+	if err != nil {
+		return User{}, fmt.Errorf("%s users: %w", method, err)
+	}
+
+	m, err := firstRecord(response)
+	if err != nil {
+		return User{}, fmt.Errorf("%s users: %w", method, err)
+	}
+
+	return decodeRecord[User]("0", m)
+}
+
+// structToForm encodes the non-zero fields of a model into form values, using the json tags as keys.
+// It is the counterpart of the JSON decoding of the models for the requests that write to the REST API.
+func structToForm(s interface{}) url.Values {
+
+	form := url.Values{}
+
+	encodeForm(form, "", s)
+
+	return form
+}
+
+// encodeForm adds the non-zero fields of a model to form. When prefix is not empty the keys are
+// encoded as prefix[json_tag], the way aMember expects the records of a nested block.
+func encodeForm(form url.Values, prefix string, s interface{}) {
+
+	elem := reflect.ValueOf(s)
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	for i := 0; i < elem.NumField(); i++ {
+
+		f := elem.Field(i)
+		jsonTag := elem.Type().Field(i).Tag.Get("json")
+
+		if jsonTag == "" || jsonTag == "-" || f.IsZero() {
+			continue
+		}
+
+		key := jsonTag
+		if prefix != "" {
+			key = fmt.Sprintf("%s[%s]", prefix, jsonTag)
+		}
+
+		switch v := f.Interface().(type) {
+		case String:
+			form.Set(key, string(v))
+		case Int:
+			form.Set(key, strconv.Itoa(int(v)))
+		case Float:
+			form.Set(key, strconv.FormatFloat(float64(v), 'f', -1, 64))
+		case Bool:
+			form.Set(key, "1")
+		case Money:
+			form.Set(key, v.Decimal())
+		case Period:
+			form.Set(key, v.String())
+		case string:
+			form.Set(key, v)
+		case int:
+			form.Set(key, strconv.Itoa(v))
+		case int64:
+			form.Set(key, strconv.FormatInt(v, 10))
+		case float32:
+			form.Set(key, strconv.FormatFloat(float64(v), 'f', -1, 32))
+		case bool:
+			form.Set(key, "1")
+		case CustomTime:
+			form.Set(key, v.Format("2006-01-02 15:04:05"))
+		case *CustomTime:
+			form.Set(key, v.Format("2006-01-02 15:04:05"))
+		case time.Time:
+			form.Set(key, v.Format("2006-01-02 15:04:05"))
+		case ItemOptions:
+			//aMember stores the options as a JSON object, and sends them back encoded in a string
+			b, err := json.Marshal(v)
+			if err == nil {
+				form.Set(key, string(b))
+			}
+		default:
+			//status enums
+			switch f.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				form.Set(key, strconv.FormatInt(f.Int(), 10))
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package amember
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeForm(t *testing.T) {
+
+	begin := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	item := InvoiceItem{
+		ItemID:      3,
+		ItemTitle:   "Pro",
+		Qty:         2,
+		VariableQty: true,
+		FirstPrice:  Money{Amount: 4900, Currency: "EUR"},
+		FirstPeriod: Period{Count: 1, Unit: PeriodMonth},
+		TaxRate:     21.125,
+		Options:     ItemOptions{"color": map[string]interface{}{"value": "red"}},
+	}
+
+	form := url.Values{}
+	encodeForm(form, "nested[invoice-items][0]", item)
+	encodeForm(form, "", Access{AccessID: 1, BeginDate: CustomTime{begin}})
+
+	want := map[string]string{
+		"nested[invoice-items][0][item_id]":      "3",
+		"nested[invoice-items][0][item_title]":   "Pro",
+		"nested[invoice-items][0][qty]":          "2",
+		"nested[invoice-items][0][variable_qty]": "1",
+		"nested[invoice-items][0][first_price]":  "49.00",
+		"nested[invoice-items][0][first_period]": "1m",
+		"nested[invoice-items][0][tax_rate]":     "21.125",
+		"nested[invoice-items][0][options]":      `{"color":{"value":"red"}}`,
+		"access_id":                              "1",
+		"begin_date":                             "2024-03-01 10:30:00",
+	}
+
+	for k, v := range want {
+		if got := form.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	//zero fields are not sent
+	if form.Has("nested[invoice-items][0][second_price]") || form.Has("expire_date") {
+		t.Errorf("zero fields encoded: %v", form)
+	}
+
+	//aMember sends the options back in a string, which decodes into the same options
+	b, err := json.Marshal(form.Get("nested[invoice-items][0][options]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var options ItemOptions
+	if err := json.Unmarshal(b, &options); err != nil || !reflect.DeepEqual(options, item.Options) {
+		t.Errorf("options round trip = %v, %v, want %v", options, err, item.Options)
+	}
+}